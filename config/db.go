@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"realstate-backend/models"
+	"strings"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -55,3 +57,181 @@ func ConnectDB() {
 
 	fmt.Println("Database connection established")
 }
+
+// CreateSearchIndexes adds the full-text and partial indexes AutoMigrate cannot express.
+func CreateSearchIndexes() {
+	if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_chat_messages_content_fts ON chat_messages USING GIN (to_tsvector('simple', content))").Error; err != nil {
+		log.Printf("Failed to create chat search index: %v", err)
+	}
+	// A phone number can be verified on one active account only
+	if err := DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_verified_phone ON users (phone) WHERE phone_verified AND deleted_at IS NULL").Error; err != nil {
+		log.Printf("Failed to create verified phone index: %v", err)
+	}
+}
+
+// MigrateChatParticipants copies the legacy participant1/participant2 columns
+// of two-party threads into chat_participants. Only threads without any
+// participant rows are copied, so people removed from a thread later are not
+// added back on the next start.
+func MigrateChatParticipants() {
+	if !DB.Migrator().HasColumn("chat_threads", "participant1_id") {
+		return
+	}
+
+	// Read pointers only exist on databases that ran the per-participant read cursor change
+	lastRead := [2]string{"0", "0"}
+	if DB.Migrator().HasColumn("chat_threads", "participant1_last_read_id") {
+		lastRead = [2]string{"COALESCE(t.participant1_last_read_id, 0)", "COALESCE(t.participant2_last_read_id, 0)"}
+	}
+
+	var selects []string
+	for i, column := range []string{"participant1_id", "participant2_id"} {
+		selects = append(selects, `
+			SELECT t.id, t.`+column+`,
+				CASE WHEN p.owner_id = t.`+column+` THEN 'owner' ELSE 'seeker' END,
+				`+lastRead[i]+`, COALESCE(t.updated_at, NOW())
+			FROM chat_threads t
+			LEFT JOIN properties p ON p.id = t.property_id
+			WHERE t.`+column+` IS NOT NULL AND t.`+column+` <> 0
+				AND NOT EXISTS (SELECT 1 FROM chat_participants cp WHERE cp.thread_id = t.id)`)
+	}
+
+	// One statement, so both participants of a thread are copied before the
+	// NOT EXISTS check can see either of them
+	err := DB.Exec(`
+		INSERT INTO chat_participants (thread_id, user_id, role, last_read_message_id, joined_at)
+		` + strings.Join(selects, "\n\t\tUNION ALL") + `
+		ON CONFLICT (thread_id, user_id) DO NOTHING`).Error
+	if err != nil {
+		log.Printf("Failed to migrate chat participants: %v", err)
+		return
+	}
+
+	if err := DB.Exec(`UPDATE chat_threads SET created_by_id = participant1_id
+		WHERE (created_by_id IS NULL OR created_by_id = 0) AND participant1_id IS NOT NULL`).Error; err != nil {
+		log.Printf("Failed to backfill chat thread creators: %v", err)
+	}
+}
+
+// MigrateInquiryThreads gives every inquiry created before inquiries moved onto
+// chat its own chat thread, copying the initial message and any InquiryMessage
+// rows across. The legacy rows are left in place. Safe to run on every start.
+func MigrateInquiryThreads() {
+	var inquiries []models.Inquiry
+	if err := DB.Preload("Property").Where("chat_thread_id IS NULL").Order("id ASC").Find(&inquiries).Error; err != nil {
+		log.Printf("Failed to load inquiries for chat migration: %v", err)
+		return
+	}
+
+	for _, inquiry := range inquiries {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var legacy []models.InquiryMessage
+			if err := tx.Where("inquiry_id = ?", inquiry.ID).Order("created_at ASC, id ASC").Find(&legacy).Error; err != nil {
+				return err
+			}
+
+			thread := models.ChatThread{
+				PropertyID:   &inquiry.PropertyID,
+				InquiryID:    &inquiry.ID,
+				CreatedByID:  inquiry.SeekerID,
+				UpdatedAt:    inquiry.UpdatedAt,
+				LastActivity: inquiry.UpdatedAt,
+			}
+			if err := tx.Create(&thread).Error; err != nil {
+				return err
+			}
+
+			messages := []models.ChatMessage{{
+				ThreadID:  thread.ID,
+				SenderID:  inquiry.SeekerID,
+				Content:   inquiry.InitialMessage,
+				Status:    models.MessageStatusRead,
+				CreatedAt: inquiry.CreatedAt,
+				UpdatedAt: inquiry.CreatedAt,
+			}}
+			for _, m := range legacy {
+				messages = append(messages, models.ChatMessage{
+					ThreadID:  thread.ID,
+					SenderID:  m.SenderID,
+					Content:   m.Message,
+					Status:    models.MessageStatusRead,
+					CreatedAt: m.CreatedAt,
+					UpdatedAt: m.CreatedAt,
+				})
+			}
+			if err := tx.Create(&messages).Error; err != nil {
+				return err
+			}
+			last := messages[len(messages)-1]
+
+			// History predates read receipts, so treat it as read by both sides
+			ownerRole := models.ParticipantRoleOwner
+			if inquiry.Property.PostedAs == "Broker" {
+				ownerRole = models.ParticipantRoleBroker
+			}
+			participants := []models.ChatParticipant{
+				{ThreadID: thread.ID, UserID: inquiry.SeekerID, Role: models.ParticipantRoleSeeker, LastReadMessageID: last.ID, JoinedAt: inquiry.CreatedAt},
+				{ThreadID: thread.ID, UserID: inquiry.OwnerID, Role: ownerRole, LastReadMessageID: last.ID, JoinedAt: inquiry.CreatedAt},
+			}
+			if inquiry.SeekerSharedContact {
+				participants[0].ContactSharedAt = &inquiry.UpdatedAt
+			}
+			if inquiry.OwnerSharedContact {
+				participants[1].ContactSharedAt = &inquiry.UpdatedAt
+			}
+			if err := tx.Create(&participants).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(&thread).Update("last_message", last.Content).Error; err != nil {
+				return err
+			}
+			return tx.Model(&inquiry).UpdateColumn("chat_thread_id", thread.ID).Error
+		})
+		if err != nil {
+			log.Printf("Failed to migrate inquiry %d to chat: %v", inquiry.ID, err)
+		}
+	}
+}
+
+// MigrateNotificationCategories fills Category for notifications created before
+// it existed, which otherwise default to 'system'.
+func MigrateNotificationCategories() {
+	if err := DB.Exec(`UPDATE notifications SET category = type
+		WHERE category = 'system' AND type IN ('inquiry', 'chat', 'payment')`).Error; err != nil {
+		log.Printf("Failed to backfill notification categories: %v", err)
+	}
+}
+
+// GrandfatherVerifiedEmails marks every existing account as verified when email
+// verification is first introduced, so current members are not locked out of
+// posting.
+func GrandfatherVerifiedEmails() {
+	if err := DB.Exec(`UPDATE users SET email_verified = true, email_verified_at = created_at
+		WHERE email_verified = false`).Error; err != nil {
+		log.Printf("Failed to mark existing users as verified: %v", err)
+	}
+}
+
+// MigrateSuspensions turns users taken down through reports, which used to be
+// soft-deleted and could sign straight back in, into suspended accounts. Bans
+// from the old toggle looked the same as self-deactivation and are left alone.
+func MigrateSuspensions() {
+	if err := DB.Exec(`UPDATE users SET suspended_at = deleted_at, deleted_at = NULL
+		WHERE deleted_at IS NOT NULL AND suspended_at IS NULL AND id IN (
+			SELECT target_id FROM reports WHERE target_type = 'user' AND status = 'resolved')`).Error; err != nil {
+		log.Printf("Failed to migrate suspended users: %v", err)
+	}
+}
+
+// MigratePhoneNumbers rewrites stored phone numbers in E.164 form. Numbers that
+// cannot be parsed are left for their owners to fix.
+func MigratePhoneNumbers() {
+	var users []models.User
+	DB.Unscoped().Select("id", "phone").Where("phone <> '' AND phone !~ '^\\+[0-9]+$'").Find(&users)
+	for _, user := range users {
+		if phone, err := models.NormalizePhone(user.Phone); err == nil {
+			DB.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Update("phone", phone)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"log"
+	"os"
 	"realstate-backend/models"
 	"time"
 
@@ -17,11 +20,11 @@ func SeedData() {
 		userPass, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
 
 		users := []models.User{
-			{Name: "Alok Verma", Email: "alok@example.com", Password: string(userPass), Role: "owner", Phone: "+91 94252 12345", PublicPreference: "Full"},
-			{Name: "Priya Singh", Email: "priya@example.com", Password: string(userPass), Role: "owner", Phone: "+91 94252 67890", PublicPreference: "Anonymized"},
-			{Name: "Rahul Sharma", Email: "rahul@example.com", Password: string(userPass), Role: "seeker", Phone: "+91 91790 11223", PublicPreference: "Full"},
-			{Name: "Amit Gupta", Email: "amit@example.com", Password: string(userPass), Role: "seeker", Phone: "+91 91790 44556", PublicPreference: "Anonymized"},
-			{Name: "Siddharth Jain", Email: "sid@example.com", Password: string(userPass), Role: "owner", Phone: "+91 98271 77889", PublicPreference: "Full"},
+			{Name: "Alok Verma", Email: "alok@example.com", Password: string(userPass), Role: "owner", Phone: "+919425212345", PublicPreference: "Full", EmailVerified: true},
+			{Name: "Priya Singh", Email: "priya@example.com", Password: string(userPass), Role: "owner", Phone: "+919425267890", PublicPreference: "Anonymized", EmailVerified: true},
+			{Name: "Rahul Sharma", Email: "rahul@example.com", Password: string(userPass), Role: "seeker", Phone: "+919179011223", PublicPreference: "Full", EmailVerified: true},
+			{Name: "Amit Gupta", Email: "amit@example.com", Password: string(userPass), Role: "seeker", Phone: "+919179044556", PublicPreference: "Anonymized", EmailVerified: true},
+			{Name: "Siddharth Jain", Email: "sid@example.com", Password: string(userPass), Role: "owner", Phone: "+919827177889", PublicPreference: "Full", EmailVerified: true},
 		}
 
 		for _, u := range users {
@@ -32,20 +35,22 @@ func SeedData() {
 		}
 	}
 
-	// Always ensure Admin exists and has correct credentials
-	adminPass, _ := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
-	adminUser := models.User{Name: "Master Admin", Email: "admin@rjg.com", Password: string(adminPass), Role: "admin"}
-
+	// Always ensure Admin exists. The password comes from ADMIN_PASSWORD; without
+	// it a random one is generated for a new admin and printed once.
+	adminPassword := os.Getenv("ADMIN_PASSWORD")
 	var existingAdmin models.User
-	if err := DB.Where("email = ?", adminUser.Email).First(&existingAdmin).Error; err != nil {
+	if err := DB.Where("email = ?", "admin@rjg.com").First(&existingAdmin).Error; err != nil {
+		if adminPassword == "" {
+			adminPassword = randomPassword()
+			log.Printf("Generated password for admin@rjg.com: %s (set ADMIN_PASSWORD to choose one)", adminPassword)
+		}
+		adminPass, _ := bcrypt.GenerateFromPassword([]byte(adminPassword), bcrypt.DefaultCost)
 		log.Println("Creating Admin User...")
-		DB.Create(&adminUser)
-	} else {
-		// FORCE UPDATE: Verify this user is strictly an ADMIN with known password
-		// This fixes cases where user might have accidentally changed role in UI
-		existingAdmin.Password = adminUser.Password
-		existingAdmin.Role = "admin"
-		DB.Save(&existingAdmin)
+		DB.Create(&models.User{Name: "Master Admin", Email: "admin@rjg.com", Password: string(adminPass), Role: "admin", EmailVerified: true})
+	} else if adminPassword != "" {
+		// The role is left alone so a demotion made through the dashboard sticks
+		adminPass, _ := bcrypt.GenerateFromPassword([]byte(adminPassword), bcrypt.DefaultCost)
+		DB.Model(&existingAdmin).Update("password", string(adminPass))
 	}
 
 	// Get some user IDs for seeding
@@ -196,12 +201,20 @@ func SeedData() {
 			{Key: "hero_title", Value: "Property Requirements", Group: "home", Type: "text"},
 			{Key: "hero_subtitle", Value: "Browse what buyers and tenants are looking for in Rajnandgaon, or post your own requirement to connect with property owners.", Group: "home", Type: "textarea"},
 			{Key: "about_text", Value: "The premier real estate bridge for Rajnandgaon and beyond. Verified community property intelligence.", Group: "about", Type: "textarea"},
+			{Key: "chat_contact_filter", Value: "mask", Group: "privacy", Type: "text"}, // 'mask', 'flag' or 'off'
 		}
 		for _, c := range configs {
 			DB.Create(&c)
 		}
 	}
 
+	// Quick-reply defaults live in site config so admins can edit them from the CMS
+	for _, t := range models.DefaultReplyTemplates {
+		DB.Where(models.SiteConfig{Key: t.Key}).
+			Attrs(models.SiteConfig{Value: t.Body, Group: "reply_templates", Type: "textarea"}).
+			FirstOrCreate(&models.SiteConfig{})
+	}
+
 	// FORCE UPDATE: Ensure all unverified properties/requirements are Active (Direct Listing Fix)
 	// This ensures existing items show up even if they were created before the default changed.
 	DB.Model(&models.Property{}).Where("is_verified = ?", false).Update("is_active", true)
@@ -209,3 +222,9 @@ func SeedData() {
 
 	log.Println("Data seeding successfully updated.")
 }
+
+func randomPassword() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
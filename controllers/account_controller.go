@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"realstate-backend/config"
+	"realstate-backend/middleware"
+	"realstate-backend/models"
+	"realstate-backend/notify"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+
+	// Per account: at most this many emails of one kind an hour, and not
+	// two within tokenCooldown
+	tokensPerHour = 3
+	tokenCooldown = time.Minute
+)
+
+var errTokenRateLimited = errors.New("too many emails requested")
+
+// Reset requests per email address, on top of the per-IP route limit
+var forgotPasswordLimiter = middleware.NewLimiter(5, time.Hour)
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+// issueUserToken creates a single-use token for user and returns the raw value to mail out
+func issueUserToken(user models.User, purpose string, ttl time.Duration) (string, error) {
+	var recent []models.UserToken
+	config.DB.Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, purpose, time.Now().Add(-time.Hour)).
+		Order("created_at desc").Find(&recent)
+	if len(recent) >= tokensPerHour || (len(recent) > 0 && time.Since(recent[0].CreatedAt) < tokenCooldown) {
+		return "", errTokenRateLimited
+	}
+
+	raw, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	token := models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(raw),
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := config.DB.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// consumeUserToken marks a token used and returns it. Each token works once.
+func consumeUserToken(raw, purpose string) (models.UserToken, error) {
+	var token models.UserToken
+	if err := config.DB.Where("token_hash = ? AND purpose = ?", hashToken(raw), purpose).First(&token).Error; err != nil {
+		return token, errInvalidUserToken
+	}
+
+	now := time.Now()
+	result := config.DB.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+		Update("used_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return token, errInvalidUserToken
+	}
+	return token, nil
+}
+
+func sendVerificationEmail(user models.User) error {
+	raw, err := issueUserToken(user, models.TokenPurposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return notify.SendAccountEmail(user.Name, user.Email, "verify_email", "/verify-email?token="+url.QueryEscape(raw))
+}
+
+// RequestEmailVerification mails the caller a new verification link
+func RequestEmailVerification(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+		return
+	}
+
+	if err := sendVerificationEmail(user); err != nil {
+		if errors.Is(err, errTokenRateLimited) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another email"})
+			return
+		}
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// VerifyEmail confirms the address a verification link was sent to
+func VerifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := consumeUserToken(input.Token, models.TokenPurposeVerifyEmail)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This link is invalid or has expired"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, token.UserID).Error; err != nil || user.Email != token.Email {
+		// The account is gone or its address changed after the link was sent
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This link is invalid or has expired"})
+		return
+	}
+
+	if !user.EmailVerified {
+		now := time.Now()
+		config.DB.Model(&user).Updates(map[string]interface{}{"email_verified": true, "email_verified_at": now})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ForgotPassword mails a reset link. The response is the same whether or not
+// the address has an account, so it cannot be used to probe for users.
+func ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Counted per address whether or not it has an account, so the limit
+	// reveals nothing
+	if ok, retryAfter := forgotPasswordLimiter.Allow(strings.ToLower(strings.TrimSpace(input.Email))); !ok {
+		middleware.TooManyRequests(c, retryAfter)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Where("email = ?", input.Email).First(&user).Error; err == nil {
+		raw, err := issueUserToken(user, models.TokenPurposeResetPassword, resetPasswordTTL)
+		if err == nil {
+			err = notify.SendAccountEmail(user.Name, user.Email, "reset_password", "/reset-password?token="+url.QueryEscape(raw))
+		}
+		if err != nil && !errors.Is(err, errTokenRateLimited) {
+			log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for that email, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password from a reset link and signs the user out everywhere
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A token and a password of at least 8 characters are required"})
+		return
+	}
+
+	token, err := consumeUserToken(input.Token, models.TokenPurposeResetPassword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This link is invalid or has expired"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, token.UserID).Error; err != nil || user.Email != token.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This link is invalid or has expired"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"password": string(hashedPassword)}
+	// Following the link proves the user reads this inbox
+	if !user.EmailVerified {
+		updates["email_verified"] = true
+		updates["email_verified_at"] = now
+	}
+	if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	// Any other outstanding reset links die with this one
+	config.DB.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, models.TokenPurposeResetPassword).
+		Update("used_at", now)
+	revokeUserSessions(user.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in"})
+}
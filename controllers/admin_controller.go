@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"realstate-backend/config"
 	"realstate-backend/models"
+	"realstate-backend/notify"
+	"realstate-backend/rbac"
 	"strconv"
 	"time"
 
@@ -22,29 +24,80 @@ func GetUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
+// ToggleUserBan suspends an account, or lifts the suspension if it already is one
 func ToggleUserBan(c *gin.Context) {
-	id := c.Param("id")
+	adminID := c.MustGet("userID").(uint)
+
 	var user models.User
-	if err := config.DB.First(&user, id).Error; err != nil {
+	// Unscoped so accounts their owners deactivated can be banned too
+	if err := config.DB.Unscoped().First(&user, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	if user.ID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot ban yourself"})
+		return
+	}
+	if !canActOnAccount(c, adminID, user) {
+		return
+	}
 
-	// For now, simulate ban by soft deleting
-	if err := config.DB.Delete(&user).Error; err != nil {
+	if user.SuspendedAt != nil {
+		if err := config.DB.Unscoped().Model(&user).Update("suspended_at", nil).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unban user"})
+			return
+		}
+		recordAudit(adminID, "unban_user", "user", user.ID, "")
+		c.JSON(http.StatusOK, gin.H{"message": "User unbanned successfully", "suspended": false})
+		return
+	}
+
+	if err := suspendUser(user.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ban user"})
 		return
 	}
+	recordAudit(adminID, "ban_user", "user", user.ID, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "User banned successfully", "suspended": true})
+}
+
+// suspendUser bans an account and signs it out everywhere
+func suspendUser(userID uint) error {
+	if err := config.DB.Unscoped().Model(&models.User{}).Where("id = ?", userID).Update("suspended_at", time.Now()).Error; err != nil {
+		return err
+	}
+	revokeUserSessions(userID)
+	return nil
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deactivated successfully"})
+// canActOnAccount checks that the admin may ban, delete or change the role of
+// target. Staff accounts are as sensitive as granting a staff role, so they
+// need roles.manage. It writes the 403 itself.
+func canActOnAccount(c *gin.Context, adminID uint, target models.User) bool {
+	if !rbac.IsAccountRole(target.Role) && !rbac.Can(adminID, rbac.PermRolesManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to do this"})
+		return false
+	}
+	return true
 }
 
 func DeleteUser(c *gin.Context) {
-	id := c.Param("id")
-	if err := config.DB.Unscoped().Delete(&models.User{}, id).Error; err != nil {
+	adminID := c.MustGet("userID").(uint)
+
+	var user models.User
+	if err := config.DB.Unscoped().First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !canActOnAccount(c, adminID, user) {
+		return
+	}
+
+	if err := config.DB.Unscoped().Delete(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to permanently delete user"})
 		return
 	}
+	config.DB.Where("user_id = ?", user.ID).Delete(&models.Session{})
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted permanently"})
 }
 
@@ -66,11 +119,41 @@ func UpdateUserRole(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Model(&models.User{}).Where("id = ?", id).Update("role", input.Role).Error; err != nil {
+	// Account roles are free to assign; staff roles need roles.manage
+	adminID := c.MustGet("userID").(uint)
+	if !rbac.IsAccountRole(input.Role) {
+		var role models.Role
+		if err := config.DB.Where("name = ?", input.Role).First(&role).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+			return
+		}
+		if !rbac.Can(adminID, rbac.PermRolesManage) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to do this"})
+			return
+		}
+	}
+	if uint(id) == adminID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	var target models.User
+	if err := config.DB.First(&target, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	// Demoting staff is as sensitive as promoting someone to staff
+	if !canActOnAccount(c, adminID, target) {
+		return
+	}
+
+	if err := config.DB.Model(&target).Update("role", input.Role).Error; err != nil {
 		fmt.Printf("Error updating role: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
 		return
 	}
+	rbac.Invalidate(uint(id))
+	recordAudit(adminID, "change_user_role", "user", uint(id), input.Role)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully"})
 }
@@ -118,18 +201,19 @@ func TogglePropertyVerification(c *gin.Context) {
 	}
 
 	// Create Notification
-	status := "Rejected"
+	status, severity := "Rejected", models.SeverityWarning
 	if property.IsVerified {
-		status = "Approved"
-	}
-	content := "Your property '" + property.Title + "' has been " + status + "."
-	notification := models.Notification{
-		UserID:  property.OwnerID,
-		Content: content,
-		Type:    "system",
-		IsRead:  false,
-	}
-	config.DB.Create(&notification)
+		status, severity = "Approved", models.SeveritySuccess
+	}
+	notify.Send(models.Notification{
+		UserID:     property.OwnerID,
+		Content:    "Your property '" + property.Title + "' has been " + status + ".",
+		Category:   models.NotificationCategoryListing,
+		Severity:   severity,
+		EntityType: "property",
+		EntityID:   &property.ID,
+		Data:       models.JSONMap{"status": status},
+	})
 
 	c.JSON(http.StatusOK, property)
 }
@@ -159,18 +243,19 @@ func TogglePropertyActive(c *gin.Context) {
 	config.DB.Save(&property)
 
 	// Create Notification
-	status := "Deactivated"
+	status, severity := "Deactivated", models.SeverityWarning
 	if property.IsActive {
-		status = "Activated"
-	}
-	content := "Your property '" + property.Title + "' is now " + status + "."
-	notification := models.Notification{
-		UserID:  property.OwnerID,
-		Content: content,
-		Type:    "system",
-		IsRead:  false,
-	}
-	config.DB.Create(&notification)
+		status, severity = "Activated", models.SeveritySuccess
+	}
+	notify.Send(models.Notification{
+		UserID:     property.OwnerID,
+		Content:    "Your property '" + property.Title + "' is now " + status + ".",
+		Category:   models.NotificationCategoryListing,
+		Severity:   severity,
+		EntityType: "property",
+		EntityID:   &property.ID,
+		Data:       models.JSONMap{"status": status},
+	})
 
 	c.JSON(http.StatusOK, property)
 }
@@ -209,18 +294,19 @@ func ToggleRequirementVerification(c *gin.Context) {
 	}
 
 	// Create Notification
-	status := "Rejected"
+	status, severity := "Rejected", models.SeverityWarning
 	if requirement.IsVerified {
-		status = "Approved"
-	}
-	content := "Your requirement for '" + requirement.Type + "' in " + requirement.Location + " has been " + status + "."
-	notification := models.Notification{
-		UserID:  requirement.UserID,
-		Content: content,
-		Type:    "system",
-		IsRead:  false,
-	}
-	config.DB.Create(&notification)
+		status, severity = "Approved", models.SeveritySuccess
+	}
+	notify.Send(models.Notification{
+		UserID:     requirement.UserID,
+		Content:    "Your requirement for '" + requirement.Type + "' in " + requirement.Location + " has been " + status + ".",
+		Category:   models.NotificationCategoryRequirement,
+		Severity:   severity,
+		EntityType: "requirement",
+		EntityID:   &requirement.ID,
+		Data:       models.JSONMap{"status": status},
+	})
 
 	c.JSON(http.StatusOK, requirement)
 }
@@ -240,18 +326,19 @@ func ToggleRequirementActive(c *gin.Context) {
 	}
 
 	// Create Notification
-	status := "Deactivated"
+	status, severity := "Deactivated", models.SeverityWarning
 	if requirement.IsActive {
-		status = "Activated"
-	}
-	content := "Your requirement for '" + requirement.Type + "' in " + requirement.Location + " is now " + status + "."
-	notification := models.Notification{
-		UserID:  requirement.UserID,
-		Content: content,
-		Type:    "system",
-		IsRead:  false,
-	}
-	config.DB.Create(&notification)
+		status, severity = "Activated", models.SeveritySuccess
+	}
+	notify.Send(models.Notification{
+		UserID:     requirement.UserID,
+		Content:    "Your requirement for '" + requirement.Type + "' in " + requirement.Location + " is now " + status + ".",
+		Category:   models.NotificationCategoryRequirement,
+		Severity:   severity,
+		EntityType: "requirement",
+		EntityID:   &requirement.ID,
+		Data:       models.JSONMap{"status": status},
+	})
 
 	c.JSON(http.StatusOK, requirement)
 }
@@ -388,13 +475,11 @@ func SendUserMessage(c *gin.Context) {
 		return
 	}
 
-	notification := models.Notification{
-		UserID:  user.ID,
-		Content: "Message from Admin: " + input.Content,
-		Type:    "system",
-		IsRead:  false,
-	}
-	config.DB.Create(&notification)
+	notify.Send(models.Notification{
+		UserID:   user.ID,
+		Content:  "Message from Admin: " + input.Content,
+		Category: models.NotificationCategoryAccount,
+	})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Message sent successfully"})
 }
@@ -1,14 +1,15 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
-	"os"
 	"realstate-backend/config"
 	"realstate-backend/models"
+	"realstate-backend/rbac"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -28,16 +29,31 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	if input.Phone != "" {
+		phone, err := models.NormalizePhone(input.Phone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+			return
+		}
+		input.Phone = phone
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
 	}
 
+	// Only member roles can be picked at sign-up; developers and brokers
+	// apply through onboarding, and staff roles are granted by admins
 	role := input.Role
 	if role == "" {
 		role = "seeker"
 	}
+	if role != "seeker" && role != "owner" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be seeker or owner. Developers and brokers can apply from their account after signing up."})
+		return
+	}
 
 	user := models.User{
 		Name:        input.Name,
@@ -53,7 +69,11 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
+	if err := sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully. Please check your email to verify your address."})
 }
 
 func Login(c *gin.Context) {
@@ -79,33 +99,16 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Reactivate if account was deactivated (DeletedAt is not null)
-	if user.DeletedAt.Valid {
-		user.DeletedAt = gorm.DeletedAt{}
-		config.DB.Save(&user)
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":  user.ID,
-		"role": user.Role,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(),
-	})
-
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	if !reactivateAccount(c, &user) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"role":  user.Role,
-			"email": user.Email,
-			"phone": user.Phone,
-		},
+	finishLogin(c, user, gin.H{
+		"id":    user.ID,
+		"name":  user.Name,
+		"role":  user.Role,
+		"email": user.Email,
+		"phone": user.Phone,
 	})
 }
 
@@ -121,7 +124,7 @@ func AdminLogin(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := config.DB.Where("email = ? AND role = ?", input.Email, "admin").First(&user).Error; err != nil {
+	if err := config.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin credentials"})
 		return
 	}
@@ -131,32 +134,30 @@ func AdminLogin(c *gin.Context) {
 		return
 	}
 
-	// OVERRIDE: Force role to admin for master email in TOKEN regardless of DB
-	// This ensures JWT claims are correct even if DB is stale
-	actualRole := user.Role
-	if user.Email == "admin@rjg.com" {
-		actualRole = "admin"
+	// Any account whose roles grant admin panel access may sign in here
+	if !rbac.IsStaff(user.ID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin credentials"})
+		return
+	}
+	if !reactivateAccount(c, &user) {
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":  user.ID,
-		"role": actualRole,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(),
-	})
-
-	tokenString, _ := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"user":  gin.H{"id": user.ID, "name": user.Name, "role": actualRole},
+	// Staff always go through two-factor authentication
+	finishLogin(c, user, gin.H{
+		"id":          user.ID,
+		"name":        user.Name,
+		"role":        user.Role,
+		"permissions": rbac.List(user.ID),
 	})
 }
 
+// GoogleLogin signs in with a Google ID token. The identity is the token's
+// subject; an existing account with the same verified email is linked the
+// first time.
 func GoogleLogin(c *gin.Context) {
 	var input struct {
-		Email string `json:"email" binding:"required"`
-		Name  string `json:"name" binding:"required"`
-		Token string `json:"token"`
+		Token string `json:"token" binding:"required"` // Google ID token (credential)
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -164,45 +165,104 @@ func GoogleLogin(c *gin.Context) {
 		return
 	}
 
-	// TODO: Verify Google Token using Google API (Recommended for production)
+	claims, err := verifyGoogleIDToken(input.Token)
+	if err != nil {
+		log.Printf("Rejected Google sign-in: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Google token"})
+		return
+	}
 
+	now := time.Now()
 	var user models.User
-	// Check if user exists
-	if err := config.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	// Use Unscoped to find deactivated users too
+	err = config.DB.Unscoped().Where("google_subject = ?", claims.Subject).First(&user).Error
+	if err == gorm.ErrRecordNotFound {
+		err = config.DB.Unscoped().Where("email = ?", claims.Email).First(&user).Error
+		switch {
+		case err == gorm.ErrRecordNotFound:
 			// Register new user
 			user = models.User{
-				Name:     input.Name,
-				Email:    input.Email,
-				Role:     "seeker", // Default role
-				Password: "",       // No password for Google Auth
+				Name:          claims.Name,
+				Email:         claims.Email,
+				Role:          "seeker", // Default role
+				Password:      "",       // No password for Google Auth
+				GoogleSubject: &claims.Subject,
+				// Google only issues tokens with email_verified for addresses it has confirmed
+				EmailVerified:   true,
+				EmailVerifiedAt: &now,
+			}
+			if user.Name == "" {
+				user.Name = strings.Split(claims.Email, "@")[0]
 			}
-			if err := config.DB.Create(&user).Error; err != nil {
+			if err = config.DB.Create(&user).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 				return
 			}
-		} else {
+		case err != nil:
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 			return
+		case user.GoogleSubject != nil:
+			c.JSON(http.StatusConflict, gin.H{"error": "This email is linked to a different Google account"})
+			return
+		case rbac.IsStaff(user.ID):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Staff accounts must sign in with a password"})
+			return
+		default:
+			updates := map[string]interface{}{"google_subject": claims.Subject}
+			if !user.EmailVerified {
+				// Nobody proved they own this address before; Google just did. Whoever
+				// registered it may have been squatting, so drop every credential
+				// they could have set and sign them out.
+				updates["email_verified"] = true
+				updates["email_verified_at"] = now
+				updates["password"] = ""
+				updates["phone_verified"] = false
+				updates["phone_verified_at"] = nil
+			}
+			if err = config.DB.Unscoped().Model(&user).Updates(updates).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link Google account"})
+				return
+			}
+			if !user.EmailVerified {
+				revokeUserSessions(user.ID)
+				if err = clearTwoFactor(user.ID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link Google account"})
+					return
+				}
+				user.TwoFactorEnabled = false
+				user.PhoneVerified = false
+				user.Password = ""
+			}
 		}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
 	}
 
-	// Generate JWT
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":  user.ID,
-		"role": user.Role,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(),
-	})
-
-	tokenString, _ := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if !reactivateAccount(c, &user) {
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"role":  user.Role,
-			"email": user.Email,
-		},
+	finishLogin(c, user, gin.H{
+		"id":    user.ID,
+		"name":  user.Name,
+		"role":  user.Role,
+		"email": user.Email,
 	})
 }
+
+// reactivateAccount lets a user sign back into an account they deactivated
+// themselves. Suspended accounts stay locked; it writes the 403 and returns
+// false for those.
+func reactivateAccount(c *gin.Context, user *models.User) bool {
+	if user.SuspendedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been suspended"})
+		return false
+	}
+	// Deactivated accounts are soft-deleted (DeletedAt is not null)
+	if user.DeletedAt.Valid {
+		user.DeletedAt = gorm.DeletedAt{}
+		config.DB.Unscoped().Model(user).Update("deleted_at", nil)
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBlockedUsers returns the users the authenticated user has blocked
+func GetBlockedUsers(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	var blocks []models.UserBlock
+	if err := config.DB.Preload("Blocked").Where("blocker_id = ?", userID).Order("created_at desc").Find(&blocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocked users"})
+		return
+	}
+	c.JSON(http.StatusOK, blocks)
+}
+
+// BlockUser stops another user from contacting the authenticated user
+func BlockUser(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	var input struct {
+		UserID uint `json:"user_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.UserID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot block yourself"})
+		return
+	}
+
+	var target models.User
+	if err := config.DB.First(&target, input.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	block := models.UserBlock{BlockerID: userID, BlockedID: target.ID}
+	if err := config.DB.Where(block).FirstOrCreate(&block).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked", "isBlocked": true})
+}
+
+// UnblockUser removes a block placed by the authenticated user
+func UnblockUser(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	if err := config.DB.Where("blocker_id = ? AND blocked_id = ?", userID, c.Param("userId")).
+		Delete(&models.UserBlock{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked", "isBlocked": false})
+}
+
+// isBlockedBetween reports whether either user has blocked the other
+func isBlockedBetween(userID uint, otherIDs ...uint) bool {
+	if len(otherIDs) == 0 {
+		return false
+	}
+	var count int64
+	config.DB.Model(&models.UserBlock{}).
+		Where("(blocker_id = ? AND blocked_id IN ?) OR (blocked_id = ? AND blocker_id IN ?)",
+			userID, otherIDs, userID, otherIDs).
+		Count(&count)
+	return count > 0
+}
+
+// isBlockedBy reports whether any of the given users has blocked userID
+func isBlockedBy(userID uint, otherIDs []uint) bool {
+	if len(otherIDs) == 0 {
+		return false
+	}
+	var count int64
+	config.DB.Model(&models.UserBlock{}).
+		Where("blocked_id = ? AND blocker_id IN ?", userID, otherIDs).
+		Count(&count)
+	return count > 0
+}
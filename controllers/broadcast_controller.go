@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/notify"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type broadcastInput struct {
+	Title       string                  `json:"title" binding:"required"`
+	Content     string                  `json:"content" binding:"required"`
+	Category    string                  `json:"category"`
+	Severity    string                  `json:"severity"`
+	ActionURL   string                  `json:"action_url"`
+	Segment     models.BroadcastSegment `json:"segment"`
+	Channels    []string                `json:"channels"`
+	ScheduledAt *time.Time              `json:"scheduled_at"`
+	SendNow     bool                    `json:"send_now"`
+}
+
+var validSeverities = map[string]bool{
+	models.SeverityInfo: true, models.SeveritySuccess: true, models.SeverityWarning: true, models.SeverityCritical: true,
+}
+
+// validate fills defaults and checks the category, severity, channels and schedule
+func (in *broadcastInput) validate() string {
+	if in.Category == "" {
+		in.Category = models.NotificationCategorySystem
+	}
+	if in.Severity == "" {
+		in.Severity = models.SeverityInfo
+	}
+
+	validCategory := false
+	for _, category := range models.NotificationCategories {
+		validCategory = validCategory || category == in.Category
+	}
+	if !validCategory {
+		return "Unknown category " + in.Category
+	}
+	if !validSeverities[in.Severity] {
+		return "severity must be info, success, warning or critical"
+	}
+	for _, channel := range in.Channels {
+		if notify.ChannelFrequencies(channel) == nil {
+			return "Unknown channel " + channel
+		}
+	}
+	if in.ScheduledAt != nil && in.ScheduledAt.Before(time.Now()) {
+		return "scheduled_at must be in the future"
+	}
+	if in.ScheduledAt != nil && in.SendNow {
+		return "Use either scheduled_at or send_now"
+	}
+	return ""
+}
+
+func (in *broadcastInput) apply(broadcast *models.Broadcast) {
+	broadcast.Title = in.Title
+	broadcast.Content = in.Content
+	broadcast.Category = in.Category
+	broadcast.Severity = in.Severity
+	broadcast.ActionURL = in.ActionURL
+	broadcast.Segment = in.Segment
+	broadcast.Channels = in.Channels
+	broadcast.ScheduledAt = in.ScheduledAt
+
+	switch {
+	case in.SendNow:
+		broadcast.Status = models.BroadcastStatusSending
+	case in.ScheduledAt != nil:
+		broadcast.Status = models.BroadcastStatusScheduled
+	default:
+		broadcast.Status = models.BroadcastStatusDraft
+	}
+}
+
+// GetBroadcasts lists broadcasts, newest first
+func GetBroadcasts(c *gin.Context) {
+	query := config.DB.Preload("CreatedBy")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var broadcasts []models.Broadcast
+	if err := query.Order("created_at desc").Find(&broadcasts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, broadcasts)
+}
+
+// GetBroadcast returns a broadcast with its delivery stats
+func GetBroadcast(c *gin.Context) {
+	var broadcast models.Broadcast
+	if err := config.DB.Preload("CreatedBy").First(&broadcast, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Broadcast not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"broadcast": broadcast, "stats": broadcastStats(broadcast)})
+}
+
+// broadcastStats counts, per channel, users who got the broadcast and users
+// whose every attempt failed, plus how many have read it in the app.
+func broadcastStats(broadcast models.Broadcast) gin.H {
+	var rows []struct {
+		Channel   string
+		Delivered int64
+		Failed    int64
+	}
+	config.DB.Raw(`SELECT channel,
+			COUNT(*) FILTER (WHERE ok) AS delivered,
+			COUNT(*) FILTER (WHERE NOT ok) AS failed
+		FROM (
+			SELECT channel, user_id, bool_or(status = ?) AS ok
+			FROM notification_deliveries WHERE broadcast_id = ?
+			GROUP BY channel, user_id
+		) per_user GROUP BY channel`, models.DeliveryStatusSent, broadcast.ID).Scan(&rows)
+
+	channels := gin.H{}
+	for _, row := range rows {
+		channels[row.Channel] = gin.H{"delivered": row.Delivered, "failed": row.Failed}
+	}
+
+	var read int64
+	config.DB.Model(&models.Notification{}).Where("broadcast_id = ? AND is_read = ?", broadcast.ID, true).Count(&read)
+
+	return gin.H{
+		"recipients": broadcast.Recipients,
+		"processed":  broadcast.Sent,
+		"channels":   channels,
+		"read":       read,
+	}
+}
+
+// PreviewBroadcastSegment counts the users a segment would reach, by role, with a few examples
+func PreviewBroadcastSegment(c *gin.Context) {
+	var input struct {
+		Segment models.BroadcastSegment `json:"segment"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total int64
+	if err := notify.SegmentQuery(input.Segment).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var byRole []struct {
+		Role  string `json:"role"`
+		Count int64  `json:"count"`
+	}
+	notify.SegmentQuery(input.Segment).Select("role, COUNT(*) AS count").Group("role").Scan(&byRole)
+
+	var sample []models.User
+	notify.SegmentQuery(input.Segment).Order("created_at desc").Limit(10).Find(&sample)
+
+	c.JSON(http.StatusOK, gin.H{"total": total, "by_role": byRole, "sample": sample})
+}
+
+// CreateBroadcast saves a broadcast as a draft, schedules it, or starts sending right away
+func CreateBroadcast(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var input broadcastInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := input.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	broadcast := models.Broadcast{CreatedByID: adminID}
+	input.apply(&broadcast)
+	if err := config.DB.Create(&broadcast).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create broadcast"})
+		return
+	}
+
+	if broadcast.Status == models.BroadcastStatusSending {
+		recordAudit(adminID, "send_broadcast", "broadcast", broadcast.ID, broadcast.Title)
+		go notify.RunBroadcast(broadcast.ID)
+	}
+
+	c.JSON(http.StatusCreated, broadcast)
+}
+
+// UpdateBroadcast edits a broadcast that has not started sending
+func UpdateBroadcast(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var broadcast models.Broadcast
+	if err := config.DB.First(&broadcast, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Broadcast not found"})
+		return
+	}
+	if broadcast.Status != models.BroadcastStatusDraft && broadcast.Status != models.BroadcastStatusScheduled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft or scheduled broadcasts can be edited"})
+		return
+	}
+
+	var input broadcastInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := input.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	input.apply(&broadcast)
+	if err := config.DB.Save(&broadcast).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update broadcast"})
+		return
+	}
+
+	if broadcast.Status == models.BroadcastStatusSending {
+		recordAudit(adminID, "send_broadcast", "broadcast", broadcast.ID, broadcast.Title)
+		go notify.RunBroadcast(broadcast.ID)
+	}
+
+	c.JSON(http.StatusOK, broadcast)
+}
+
+// SendBroadcast starts sending a draft or scheduled broadcast now
+func SendBroadcast(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var broadcast models.Broadcast
+	if err := config.DB.First(&broadcast, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Broadcast not found"})
+		return
+	}
+
+	// Conditional update so a double click or the scheduler cannot start it twice
+	result := config.DB.Model(&models.Broadcast{}).
+		Where("id = ? AND status IN ?", broadcast.ID, []string{models.BroadcastStatusDraft, models.BroadcastStatusScheduled}).
+		Update("status", models.BroadcastStatusSending)
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Broadcast has already been sent or cancelled"})
+		return
+	}
+
+	recordAudit(adminID, "send_broadcast", "broadcast", broadcast.ID, broadcast.Title)
+	go notify.RunBroadcast(broadcast.ID)
+
+	broadcast.Status = models.BroadcastStatusSending
+	c.JSON(http.StatusOK, broadcast)
+}
+
+// CancelBroadcast stops a scheduled broadcast, or one that is partway through sending
+func CancelBroadcast(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var broadcast models.Broadcast
+	if err := config.DB.First(&broadcast, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Broadcast not found"})
+		return
+	}
+
+	result := config.DB.Model(&models.Broadcast{}).
+		Where("id = ? AND status IN ?", broadcast.ID, []string{models.BroadcastStatusScheduled, models.BroadcastStatusSending}).
+		Update("status", models.BroadcastStatusCancelled)
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only scheduled or sending broadcasts can be cancelled"})
+		return
+	}
+
+	recordAudit(adminID, "cancel_broadcast", "broadcast", broadcast.ID, fmt.Sprintf("%s (%d users reached)", broadcast.Title, broadcast.Sent))
+
+	broadcast.Status = models.BroadcastStatusCancelled
+	c.JSON(http.StatusOK, broadcast)
+}
+
+// DeleteBroadcast removes a draft or cancelled broadcast
+func DeleteBroadcast(c *gin.Context) {
+	result := config.DB.Where("id = ? AND status IN ?", c.Param("id"),
+		[]string{models.BroadcastStatusDraft, models.BroadcastStatusCancelled}).Delete(&models.Broadcast{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete broadcast"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft or cancelled broadcasts can be deleted"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Broadcast deleted"})
+}
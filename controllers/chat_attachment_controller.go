@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxChatImageSize    = 10 << 20 // 10 MB
+	maxChatDocumentSize = 20 << 20 // 20 MB
+	maxChatVoiceSize    = 5 << 20  // 5 MB
+	maxVoiceNoteSeconds = 300
+	chatThumbnailSize   = 320
+	maxThumbnailPixels  = 40_000_000 // Skip thumbnails for absurdly large images
+)
+
+// Content types accepted for each attachment kind, keyed by sniffed MIME type.
+var chatAttachmentKinds = map[string]string{
+	"image/jpeg":      models.AttachmentKindImage,
+	"image/png":       models.AttachmentKindImage,
+	"image/gif":       models.AttachmentKindImage,
+	"image/webp":      models.AttachmentKindImage,
+	"application/pdf": models.AttachmentKindDocument,
+	"audio/mpeg":      models.AttachmentKindVoice,
+	"audio/wave":      models.AttachmentKindVoice,
+	"audio/ogg":       models.AttachmentKindVoice,
+	// Containers that may hold audio or video; accepted as voice notes only
+	// when the client declares an audio content type.
+	"application/ogg": models.AttachmentKindVoice,
+	"video/webm":      models.AttachmentKindVoice,
+	"video/mp4":       models.AttachmentKindVoice,
+}
+
+func chatAttachmentDir() string {
+	if dir := os.Getenv("CHAT_ATTACHMENT_DIR"); dir != "" {
+		return dir
+	}
+	// Deliberately outside ./uploads, which is served publicly
+	return "./chat_attachments"
+}
+
+// UploadChatAttachment stores a file for a thread. The returned attachment is
+// linked to a message by passing its ID in attachment_ids when sending.
+func (cc *ChatController) UploadChatAttachment(c *gin.Context) {
+	threadID := c.Param("id")
+	userID := c.MustGet("userID").(uint)
+
+	thread, _, ok := loadThreadForParticipant(c, threadID, userID)
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	mimeType, kind, err := classifyChatAttachment(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	duration := 0
+	if kind == models.AttachmentKindVoice {
+		if d, err := strconv.Atoi(c.PostForm("duration_seconds")); err == nil && d > 0 {
+			duration = d
+		}
+		if duration > maxVoiceNoteSeconds {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Voice notes are limited to %d seconds", maxVoiceNoteSeconds)})
+			return
+		}
+	}
+
+	dir := filepath.Join(chatAttachmentDir(), strconv.Itoa(int(thread.ID)))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+		return
+	}
+
+	// Never trust the client filename for the on-disk path
+	stored := fmt.Sprintf("%d-%d%s", time.Now().UnixNano(), userID, strings.ToLower(filepath.Ext(file.Filename)))
+	path := filepath.Join(dir, stored)
+	if err := c.SaveUploadedFile(file, path); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+		return
+	}
+
+	attachment := models.ChatAttachment{
+		ThreadID:        thread.ID,
+		UploaderID:      userID,
+		Kind:            kind,
+		FileName:        filepath.Base(file.Filename),
+		MimeType:        mimeType,
+		Size:            file.Size,
+		DurationSeconds: duration,
+		StoragePath:     path,
+	}
+
+	if kind == models.AttachmentKindImage {
+		thumbPath := path + ".thumb.jpg"
+		if err := writeChatThumbnail(path, thumbPath); err == nil {
+			attachment.ThumbnailPath = thumbPath
+		}
+	}
+
+	if err := config.DB.Create(&attachment).Error; err != nil {
+		os.Remove(path)
+		if attachment.ThumbnailPath != "" {
+			os.Remove(attachment.ThumbnailPath)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+	attachment.FillURLs()
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+func (cc *ChatController) DownloadChatAttachment(c *gin.Context) {
+	attachment, ok := loadAuthorizedAttachment(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", attachment.MimeType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.FileAttachment(attachment.StoragePath, attachment.FileName)
+}
+
+func (cc *ChatController) GetChatAttachmentThumbnail(c *gin.Context) {
+	attachment, ok := loadAuthorizedAttachment(c)
+	if !ok {
+		return
+	}
+
+	if attachment.ThumbnailPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No thumbnail for this attachment"})
+		return
+	}
+
+	c.Header("Content-Type", "image/jpeg")
+	c.File(attachment.ThumbnailPath)
+}
+
+// loadAuthorizedAttachment fetches the attachment named in the route and checks
+// that the caller may read it. It writes the error response itself.
+func loadAuthorizedAttachment(c *gin.Context) (models.ChatAttachment, bool) {
+	userID := c.MustGet("userID").(uint)
+
+	var attachment models.ChatAttachment
+	if err := config.DB.First(&attachment, c.Param("attachmentId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return attachment, false
+	}
+
+	var participants int64
+	config.DB.Model(&models.ChatParticipant{}).
+		Where("thread_id = ? AND user_id = ?", attachment.ThreadID, userID).Count(&participants)
+	if participants == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return attachment, false
+	}
+
+	// Files not yet sent are private to the uploader
+	if attachment.MessageID == nil && attachment.UploaderID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return attachment, false
+	}
+
+	// Deleting a message takes its files with it
+	if attachment.MessageID != nil {
+		var deleted int64
+		config.DB.Model(&models.ChatMessage{}).Where("id = ? AND is_deleted = ?", *attachment.MessageID, true).Count(&deleted)
+		if deleted > 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return attachment, false
+		}
+	}
+
+	return attachment, true
+}
+
+// classifyChatAttachment sniffs the uploaded file's content and checks it
+// against the allowed types and per-kind size limits.
+func classifyChatAttachment(file *multipart.FileHeader) (string, string, error) {
+	mimeType, err := sniffContentType(file)
+	if err != nil {
+		return "", "", err
+	}
+
+	kind, ok := chatAttachmentKinds[mimeType]
+	if !ok {
+		return "", "", fmt.Errorf("Unsupported file type %s", mimeType)
+	}
+
+	if kind == models.AttachmentKindVoice && !strings.HasPrefix(mimeType, "audio/") {
+		declared := file.Header.Get("Content-Type")
+		if !strings.HasPrefix(declared, "audio/") {
+			return "", "", fmt.Errorf("Unsupported file type %s", mimeType)
+		}
+		mimeType = declared
+	}
+
+	limit := int64(maxChatImageSize)
+	switch kind {
+	case models.AttachmentKindDocument:
+		limit = maxChatDocumentSize
+	case models.AttachmentKindVoice:
+		limit = maxChatVoiceSize
+	}
+	if file.Size > limit {
+		return "", "", fmt.Errorf("File exceeds the %d MB limit for %s attachments", limit>>20, kind)
+	}
+
+	return mimeType, kind, nil
+}
+
+// sniffContentType detects an uploaded file's MIME type from its first bytes
+// rather than trusting the client.
+func sniffContentType(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("Failed to read file")
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("Failed to read file")
+	}
+
+	mimeType := http.DetectContentType(head[:n])
+	if i := strings.Index(mimeType, ";"); i != -1 {
+		mimeType = mimeType[:i]
+	}
+	return mimeType, nil
+}
+
+// writeChatThumbnail writes a JPEG no larger than chatThumbnailSize on either
+// side. Formats the standard library cannot decode (e.g. WebP) return an error
+// and simply get no thumbnail.
+func writeChatThumbnail(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	cfg, _, err := image.DecodeConfig(in)
+	if err != nil {
+		return err
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return fmt.Errorf("image too large for thumbnail")
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(in)
+	if err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	scale := float64(chatThumbnailSize) / float64(max(w, h))
+	if scale > 1 {
+		scale = 1
+	}
+	tw, th := max(1, int(float64(w)*scale)), max(1, int(float64(h)*scale))
+
+	// Nearest-neighbour sampling is plenty for a chat preview
+	thumb := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		sy := bounds.Min.Y + y*h/th
+		for x := 0; x < tw; x++ {
+			sx := bounds.Min.X + x*w/tw
+			thumb.Set(x, y, img.At(sx, sy))
+		}
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return jpeg.Encode(out, thumb, &jpeg.Options{Quality: 80})
+}
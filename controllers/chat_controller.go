@@ -1,16 +1,26 @@
 package controllers
 
 import (
+	"errors"
+	"html"
 	"net/http"
 	"realstate-backend/config"
 	"realstate-backend/models"
 	"realstate-backend/ws"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var errInvalidAttachments = errors.New("One or more attachments are invalid or already sent")
+
+// chatChangesLimit caps how many changed messages a single delta sync returns.
+const chatChangesLimit = 500
+
 type ChatController struct {
 	Hub *ws.Hub
 }
@@ -22,131 +32,428 @@ func NewChatController(hub *ws.Hub) *ChatController {
 func (cc *ChatController) GetMyThreads(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
 
+	query := config.DB.Preload("Participants.User").Preload("Property").
+		Joins("JOIN chat_participants me ON me.thread_id = chat_threads.id AND me.user_id = ?", userID).
+		Where("me.is_deleted_for_me = ?", false)
+
+	// Archived threads are hidden unless asked for; archived=all returns both
+	switch c.Query("archived") {
+	case "all":
+	case "true":
+		query = query.Where("me.is_archived = ?", true)
+	default:
+		query = query.Where("me.is_archived = ?", false)
+	}
+	if muted := c.Query("muted"); muted != "" {
+		if muted == "true" {
+			query = query.Where("me.is_muted = ? AND (me.muted_until IS NULL OR me.muted_until > ?)", true, time.Now())
+		} else {
+			query = query.Where("me.is_muted = ? OR me.muted_until <= ?", false, time.Now())
+		}
+	}
+	if pinned := c.Query("pinned"); pinned != "" {
+		query = query.Where("me.is_pinned = ?", pinned == "true")
+	}
+
 	var threads []models.ChatThread
-	if err := config.DB.Preload("Participant1").Preload("Participant2").Preload("Property").
-		Where("participant1_id = ? OR participant2_id = ?", userID, userID).
-		Order("updated_at desc").Find(&threads).Error; err != nil {
+	if err := query.Order("me.is_pinned DESC, me.pinned_at DESC, chat_threads.updated_at DESC").
+		Find(&threads).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch threads"})
 		return
 	}
 
-	// Calculate unread counts
-	for i := range threads {
-		var count int64
-		config.DB.Model(&models.ChatMessage{}).
-			Where("thread_id = ? AND sender_id != ? AND is_read = ?", threads[i].ID, userID, false).
-			Count(&count)
-		threads[i].UnreadCount = int(count)
+	if err := attachUnreadCounts(threads, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread messages"})
+		return
 	}
 
-	c.JSON(http.StatusOK, threads)
+	// Fill in the caller's own thread state and apply the unread filter
+	now := time.Now()
+	onlyUnread := c.Query("unread") == "true"
+	filtered := threads[:0]
+	for _, thread := range threads {
+		for _, p := range thread.Participants {
+			if p.UserID == userID {
+				thread.IsArchived = p.IsArchived
+				thread.IsMuted = p.MutedAt(now)
+				thread.IsPinned = p.IsPinned
+			}
+		}
+		if onlyUnread && thread.UnreadCount == 0 {
+			continue
+		}
+		filtered = append(filtered, thread)
+	}
+
+	threadIDs := make([]uint, len(filtered))
+	for i := range filtered {
+		threadIDs[i] = filtered[i].ID
+	}
+	aliases := loadInquiryAliases(threadIDs...)
+	masker := newContactMasker()
+	for i := range filtered {
+		aliases.thread(&filtered[i])
+		masker.thread(&filtered[i])
+	}
+
+	c.JSON(http.StatusOK, filtered)
 }
 
 func (cc *ChatController) MarkThreadRead(c *gin.Context) {
-	threadID := c.Param("id")
 	userID := c.MustGet("userID").(uint)
 
-	// Mark all messages in this thread as read where user is not the sender
-	if err := config.DB.Model(&models.ChatMessage{}).
-		Where("thread_id = ? AND sender_id != ? AND is_read = ?", threadID, userID, false).
-		Update("is_read", true).Error; err != nil {
+	thread, participant, ok := loadThreadForParticipant(c, c.Param("id"), userID)
+	if !ok {
+		return
+	}
+
+	// Advance the read pointer to the newest message in the thread
+	var lastMessageID uint
+	config.DB.Model(&models.ChatMessage{}).Where("thread_id = ?", thread.ID).
+		Select("COALESCE(MAX(id), 0)").Scan(&lastMessageID)
+
+	now := time.Now()
+	var readMessages []models.ChatMessage
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&participant).Where("last_read_message_id < ?", lastMessageID).
+			Update("last_read_message_id", lastMessageID).Error; err != nil {
+			return err
+		}
+
+		// A message counts as read once every other non-observer participant
+		// has read past it
+		return tx.Model(&readMessages).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "sender_id"}}}).
+			Where("thread_id = ? AND id <= ? AND status != ?", thread.ID, lastMessageID, models.MessageStatusRead).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM chat_participants p
+				WHERE p.thread_id = chat_messages.thread_id
+					AND p.user_id != chat_messages.sender_id
+					AND p.role != ?
+					AND p.last_read_message_id < chat_messages.id
+			)`, models.ParticipantRoleObserver).
+			// Receipts leave updated_at alone so delta sync does not report them as edits
+			UpdateColumns(map[string]interface{}{
+				"status":       models.MessageStatusRead,
+				"read_at":      now,
+				"delivered_at": gorm.Expr("COALESCE(delivered_at, ?)", now),
+			}).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark messages as read"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+	// Push read receipts to the sender of each message
+	for _, msg := range readMessages {
+		cc.Hub.BroadcastToUser(msg.SenderID, gin.H{
+			"type":       "MESSAGE_STATUS_UPDATE",
+			"thread_id":  thread.ID,
+			"message_id": msg.ID,
+			"status":     models.MessageStatusRead,
+			"read_at":    now,
+		})
+	}
+
+	// Let everyone see how far this participant has read
+	cc.broadcastToThread(thread.ID, gin.H{
+		"type":                 "READ_CURSOR",
+		"thread_id":            thread.ID,
+		"user_id":              userID,
+		"last_read_message_id": lastMessageID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "last_read_id": lastMessageID})
+}
+
+// messagePreview is the text shown as a thread's last message.
+func messagePreview(message models.ChatMessage) string {
+	if strings.TrimSpace(message.Content) != "" || len(message.Attachments) == 0 {
+		return message.Content
+	}
+	switch message.Attachments[0].Kind {
+	case models.AttachmentKindImage:
+		return "[Photo]"
+	case models.AttachmentKindVoice:
+		return "[Voice note]"
+	default:
+		return "[Document]"
+	}
+}
+
+// attachUnreadCounts fills UnreadCount on each thread for the given user using a
+// single aggregate query over their participant read pointers.
+func attachUnreadCounts(threads []models.ChatThread, userID uint) error {
+	if len(threads) == 0 {
+		return nil
+	}
+
+	threadIDs := make([]uint, len(threads))
+	for i := range threads {
+		threadIDs[i] = threads[i].ID
+	}
+
+	var rows []struct {
+		ThreadID uint
+		Unread   int
+	}
+	err := config.DB.Table("chat_messages").
+		Select("chat_messages.thread_id, COUNT(*) AS unread").
+		Joins("JOIN chat_participants ON chat_participants.thread_id = chat_messages.thread_id AND chat_participants.user_id = ?", userID).
+		Where("chat_messages.thread_id IN ? AND chat_messages.sender_id != ? AND chat_messages.is_deleted = ?",
+			threadIDs, userID, false).
+		Where("chat_messages.id > chat_participants.last_read_message_id").
+		Group("chat_messages.thread_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.ThreadID] = row.Unread
+	}
+	for i := range threads {
+		threads[i].UnreadCount = counts[threads[i].ID]
+	}
+	return nil
 }
 
 func (cc *ChatController) GetThreadMessages(c *gin.Context) {
 	threadID := c.Param("id")
 	userID := c.MustGet("userID").(uint)
 
-	// Parse pagination parameters
-	page := 1
+	// Parse cursor parameters: before_id pages back through history,
+	// after_id fetches anything newer than what the client already has.
 	limit := 50
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
-	}
 	if limitStr := c.Query("limit"); limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
 			limit = l
 		}
 	}
 
-	var thread models.ChatThread
-	if err := config.DB.First(&thread, threadID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+	var beforeID, afterID uint64
+	if beforeStr := c.Query("before_id"); beforeStr != "" {
+		id, err := strconv.ParseUint(beforeStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before_id"})
+			return
+		}
+		beforeID = id
+	}
+	if afterStr := c.Query("after_id"); afterStr != "" {
+		id, err := strconv.ParseUint(afterStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after_id"})
+			return
+		}
+		afterID = id
+	}
+	if beforeID != 0 && afterID != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use either before_id or after_id, not both"})
 		return
 	}
 
-	if thread.Participant1ID != userID && thread.Participant2ID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	thread, participant, ok := loadThreadForParticipant(c, threadID, userID)
+	if !ok {
 		return
 	}
 
-	// Get paginated messages
-	var messages []models.ChatMessage
-	offset := (page - 1) * limit
+	// Fetch one extra row to know whether another page exists. History cleared
+	// with delete-for-me stays hidden.
+	query := config.DB.Preload("Sender").Preload("ReplyTo.Sender").Preload("Attachments").
+		Where("thread_id = ? AND is_deleted = ? AND id > ?", thread.ID, false, participant.ClearedUpToID).
+		Limit(limit + 1)
 
-	query := config.DB.Preload("Sender").Preload("ReplyTo.Sender").
-		Where("thread_id = ? AND is_deleted = ?", threadID, false).
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset)
+	if afterID != 0 {
+		query = query.Where("id > ?", afterID).Order("id ASC")
+	} else {
+		if beforeID != 0 {
+			query = query.Where("id < ?", beforeID)
+		}
+		query = query.Order("id DESC")
+	}
 
+	var messages []models.ChatMessage
 	if err := query.Find(&messages).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
 		return
 	}
 
-	// Update message delivery status for recipient
-	go func() {
-		config.DB.Model(&models.ChatMessage{}).
-			Where("thread_id = ? AND sender_id != ? AND status = ?",
-				threadID, userID, models.MessageStatusSent).
-			Updates(map[string]interface{}{
-				"status":       models.MessageStatusDelivered,
-				"delivered_at": time.Now(),
-			})
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
 
-		// Notify sender of delivery status update
-		var deliveredMessages []models.ChatMessage
-		config.DB.Where("thread_id = ? AND sender_id != ? AND status = ?",
-			threadID, userID, models.MessageStatusDelivered).Find(&deliveredMessages)
+	// Always return messages oldest first
+	if afterID == 0 {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
 
-		for _, msg := range deliveredMessages {
-			cc.Hub.BroadcastToUser(msg.SenderID, gin.H{
-				"type":       "MESSAGE_STATUS_UPDATE",
-				"message_id": msg.ID,
-				"status":     models.MessageStatusDelivered,
-			})
+	if err := cc.markThreadDelivered(thread.ID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update delivery status"})
+		return
+	}
+	loadInquiryAliases(thread.ID).messages(messages)
+	newContactMasker().messages(messages)
+
+	pagination := gin.H{
+		"limit":    limit,
+		"has_more": hasMore,
+	}
+	if len(messages) > 0 {
+		pagination["oldest_id"] = messages[0].ID
+		pagination["newest_id"] = messages[len(messages)-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages":   messages,
+		"pagination": pagination,
+		"typing":     cc.Hub.Typing.TypingUsers(thread.ID),
+	})
+}
+
+// GetChanges returns every message created, edited or deleted since the given
+// timestamp across all of the caller's threads, so clients can resync after
+// being offline. Delivery and read receipts are not changes; they arrive as
+// MESSAGE_STATUS_UPDATE events and with each message's current status.
+//
+// When has_more is set, call again with since=server_time and
+// after_id=last_id: many messages can share one updated_at, so the cursor is
+// the (updated_at, id) pair rather than the timestamp alone.
+func (cc *ChatController) GetChanges(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	sinceStr := c.Query("since")
+	if sinceStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since is required"})
+		return
+	}
+	since, err := time.Parse(time.RFC3339Nano, sinceStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC3339 timestamp"})
+		return
+	}
+	var afterID uint64
+	if afterStr := c.Query("after_id"); afterStr != "" {
+		if afterID, err = strconv.ParseUint(afterStr, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after_id"})
+			return
 		}
-	}()
+	}
+
+	// Captured before querying so nothing written during the request is missed
+	// on the next sync.
+	serverTime := time.Now()
+
+	var messages []models.ChatMessage
+	if err := config.DB.Preload("Sender").Preload("Attachments").
+		Joins("JOIN chat_participants ON chat_participants.thread_id = chat_messages.thread_id AND chat_participants.user_id = ?", userID).
+		Where("chat_messages.id > chat_participants.cleared_up_to_id").
+		Where("(chat_messages.updated_at, chat_messages.id) > (?, ?)", since, afterID).
+		Order("chat_messages.updated_at ASC, chat_messages.id ASC").
+		Limit(chatChangesLimit + 1).
+		Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch changes"})
+		return
+	}
+
+	hasMore := len(messages) > chatChangesLimit
+	var lastID uint
+	if hasMore {
+		messages = messages[:chatChangesLimit]
+		// Resume from the last change returned rather than the server clock
+		serverTime = messages[len(messages)-1].UpdatedAt
+		lastID = messages[len(messages)-1].ID
+	}
 
-	// Get total message count for pagination
-	var totalCount int64
-	config.DB.Model(&models.ChatMessage{}).Where("thread_id = ? AND is_deleted = ?", threadID, false).Count(&totalCount)
+	threadIDs := make([]uint, 0, len(messages))
+	for _, msg := range messages {
+		threadIDs = append(threadIDs, msg.ThreadID)
+	}
+	loadInquiryAliases(threadIDs...).messages(messages)
+	newContactMasker().messages(messages)
+
+	var created, edited, deleted []models.ChatMessage
+	for _, msg := range messages {
+		switch {
+		case msg.IsDeleted:
+			msg.Content = ""
+			msg.Attachments = nil
+			deleted = append(deleted, msg)
+		case msg.CreatedAt.After(since):
+			created = append(created, msg)
+		default:
+			edited = append(edited, msg)
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"messages": messages,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": totalCount,
-			"pages": (int(totalCount) + limit - 1) / limit,
-		},
+		"new":         created,
+		"edited":      edited,
+		"deleted":     deleted,
+		"has_more":    hasMore,
+		"server_time": serverTime.Format(time.RFC3339Nano),
+		"last_id":     lastID,
 	})
 }
 
+// markThreadDelivered moves every message the user has not yet received in a
+// thread from "sent" to "delivered" and notifies the senders once committed.
+func (cc *ChatController) markThreadDelivered(threadID uint, userID uint) error {
+	now := time.Now()
+	var delivered []models.ChatMessage
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("thread_id = ? AND sender_id != ? AND status = ?",
+				threadID, userID, models.MessageStatusSent).
+			Find(&delivered).Error; err != nil {
+			return err
+		}
+		if len(delivered) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(delivered))
+		for i := range delivered {
+			ids[i] = delivered[i].ID
+		}
+		// Receipts leave updated_at alone so delta sync does not report them as edits
+		return tx.Model(&models.ChatMessage{}).Where("id IN ?", ids).
+			UpdateColumns(map[string]interface{}{
+				"status":       models.MessageStatusDelivered,
+				"delivered_at": now,
+			}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range delivered {
+		cc.Hub.BroadcastToUser(msg.SenderID, gin.H{
+			"type":         "MESSAGE_STATUS_UPDATE",
+			"thread_id":    threadID,
+			"message_id":   msg.ID,
+			"status":       models.MessageStatusDelivered,
+			"delivered_at": now,
+		})
+	}
+	return nil
+}
+
 func (cc *ChatController) CreateThread(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
 
 	var input struct {
-		TargetUserID uint   `json:"target_user_id" binding:"required"`
-		PropertyID   *uint  `json:"property_id"`
-		Message      string `json:"message" binding:"required"`
+		TargetUserID   uint   `json:"target_user_id"`
+		ParticipantIDs []uint `json:"participant_ids"` // Additional people for a group thread
+		Title          string `json:"title"`
+		PropertyID     *uint  `json:"property_id"`
+		RequirementID  *uint  `json:"requirement_id"` // Set when proposing to a posted requirement
+		Message        string `json:"message" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -154,70 +461,178 @@ func (cc *ChatController) CreateThread(c *gin.Context) {
 		return
 	}
 
-	if userID == input.TargetUserID {
+	// A proposal goes to whoever posted the requirement, one-to-one
+	if input.RequirementID != nil {
+		var requirement models.Requirement
+		if err := config.DB.Where("is_active = ?", true).First(&requirement, *input.RequirementID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Requirement not found"})
+			return
+		}
+		if requirement.UserID == userID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot respond to your own requirement"})
+			return
+		}
+		if isBlockedBetween(userID, requirement.UserID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot contact this user"})
+			return
+		}
+		input.TargetUserID = requirement.UserID
+		input.ParticipantIDs = nil
+	}
+
+	// Collect the other participants, dropping duplicates
+	seen := map[uint]bool{userID: true}
+	var others []uint
+	for _, id := range append([]uint{input.TargetUserID}, input.ParticipantIDs...) {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			others = append(others, id)
+		}
+	}
+
+	if len(others) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot message yourself"})
 		return
 	}
 
-	// Check if thread already exists
-	var thread models.ChatThread
-	query := config.DB.Where("(participant1_id = ? AND participant2_id = ?) OR (participant1_id = ? AND participant2_id = ?)",
-		userID, input.TargetUserID, input.TargetUserID, userID)
+	if isBlockedBetween(userID, others...) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot message this user"})
+		return
+	}
 
-	if input.PropertyID != nil {
-		query = query.Where("property_id = ?", input.PropertyID)
-	} else {
-		query = query.Where("property_id IS NULL")
+	var userCount int64
+	config.DB.Model(&models.User{}).Where("id IN ?", others).Count(&userCount)
+	if int(userCount) != len(others) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One or more participants do not exist"})
+		return
 	}
 
-	if err := query.First(&thread).Error; err != nil {
-		// Create new thread
-		thread = models.ChatThread{
-			Participant1ID: userID,
-			Participant2ID: input.TargetUserID,
-			PropertyID:     input.PropertyID,
-			LastMessage:    input.Message,
-			UpdatedAt:      time.Now(),
-		}
-		if err := config.DB.Create(&thread).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create thread"})
+	var property *models.Property
+	if input.PropertyID != nil {
+		property = &models.Property{}
+		if err := config.DB.First(property, *input.PropertyID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
 			return
 		}
-	} else {
-		// Update existing thread
-		thread.LastMessage = input.Message
-		thread.UpdatedAt = time.Now()
-		config.DB.Save(&thread)
 	}
 
-	// Create message
+	isGroup := len(others) > 1
+	now := time.Now()
+
+	// One-to-one threads are reused; group threads are always new
+	var thread models.ChatThread
+	found := false
+	if !isGroup {
+		thread, found = findDirectThread(userID, others[0], input.PropertyID)
+	}
+
+	flagged := contactFilterFlags(input.Message, found && threadContactsConsented(thread.ID))
+
 	message := models.ChatMessage{
-		ThreadID:  thread.ID,
 		SenderID:  userID,
 		Content:   input.Message,
-		CreatedAt: time.Now(),
+		CreatedAt: now,
 	}
-	config.DB.Create(&message)
 
-	// Preload for the response
-	config.DB.Preload("Participant1").Preload("Participant2").Preload("Property").First(&thread, thread.ID)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if found {
+			thread.LastMessage = input.Message
+			thread.UpdatedAt = now
+			if err := tx.Save(&thread).Error; err != nil {
+				return err
+			}
+		} else {
+			thread = models.ChatThread{
+				PropertyID:  input.PropertyID,
+				Title:       input.Title,
+				IsGroup:     isGroup,
+				CreatedByID: userID,
+				LastMessage: input.Message,
+				UpdatedAt:   now,
+			}
+			if err := tx.Create(&thread).Error; err != nil {
+				return err
+			}
+
+			participants := []models.ChatParticipant{{
+				ThreadID: thread.ID,
+				UserID:   userID,
+				Role:     participantRoleFor(userID, property),
+				JoinedAt: now,
+			}}
+			for _, id := range others {
+				participants = append(participants, models.ChatParticipant{
+					ThreadID: thread.ID,
+					UserID:   id,
+					Role:     participantRoleFor(id, property),
+					JoinedAt: now,
+				})
+			}
+			if err := tx.Create(&participants).Error; err != nil {
+				return err
+			}
+		}
 
-	// Broadcast via WebSocket
-	cc.Hub.BroadcastToUser(input.TargetUserID, gin.H{
-		"type":    "NEW_MESSAGE",
-		"thread":  thread,
-		"message": message,
+		message.ThreadID = thread.ID
+		return tx.Create(&message).Error
 	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create thread"})
+		return
+	}
+
+	if flagged {
+		flagContactSharing("message", message.ID)
+	}
+	if found {
+		reviveThread(thread.ID)
+	}
+
+	// Preload for the response. Contact details stay hidden until everyone in
+	// the thread has opted in.
+	config.DB.Preload("Participants.User").Preload("Property").First(&thread, thread.ID)
+	loadInquiryAliases(thread.ID).thread(&thread)
+	masker := newContactMasker()
+	masker.thread(&thread)
+	masker.message(&message)
+
+	// Broadcast via WebSocket
+	for _, id := range others {
+		cc.Hub.BroadcastToUser(id, gin.H{
+			"type":    "NEW_MESSAGE",
+			"thread":  thread,
+			"message": message,
+		})
+	}
+
+	cc.notifyChatRecipients(thread.ID, userID, input.Message)
 
 	c.JSON(http.StatusCreated, gin.H{"thread": thread, "message": message})
 }
 
+// findDirectThread looks up the one-to-one thread between two users about a
+// property, or with no property when propertyID is nil.
+func findDirectThread(userID, otherID uint, propertyID *uint) (models.ChatThread, bool) {
+	var thread models.ChatThread
+	query := config.DB.Where("is_group = ?", false).
+		Where("id IN (?)", config.DB.Model(&models.ChatParticipant{}).Select("thread_id").Where("user_id = ?", userID)).
+		Where("id IN (?)", config.DB.Model(&models.ChatParticipant{}).Select("thread_id").Where("user_id = ?", otherID))
+
+	if propertyID != nil {
+		query = query.Where("property_id = ?", *propertyID)
+	} else {
+		query = query.Where("property_id IS NULL")
+	}
+	return thread, query.First(&thread).Error == nil
+}
+
 func (cc *ChatController) SendChatMessage(c *gin.Context) {
 	threadID := c.Param("id")
 	userID := c.MustGet("userID").(uint)
 
 	var input struct {
-		Content string `json:"content" binding:"required"`
+		Content       string `json:"content"`
+		AttachmentIDs []uint `json:"attachment_ids"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -225,17 +640,22 @@ func (cc *ChatController) SendChatMessage(c *gin.Context) {
 		return
 	}
 
-	var thread models.ChatThread
-	if err := config.DB.First(&thread, threadID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+	if strings.TrimSpace(input.Content) == "" && len(input.AttachmentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message content or an attachment is required"})
 		return
 	}
 
-	if thread.Participant1ID != userID && thread.Participant2ID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	thread, participant, ok := loadThreadForParticipant(c, threadID, userID)
+	if !ok {
 		return
 	}
 
+	if !canPostToThread(c, thread, participant) {
+		return
+	}
+
+	flagged := contactFilterFlags(input.Content, threadContactsConsented(thread.ID))
+
 	message := models.ChatMessage{
 		ThreadID:  thread.ID,
 		SenderID:  userID,
@@ -243,40 +663,100 @@ func (cc *ChatController) SendChatMessage(c *gin.Context) {
 		CreatedAt: time.Now(),
 	}
 
-	if err := config.DB.Create(&message).Error; err != nil {
+	var attachments []models.ChatAttachment
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if len(input.AttachmentIDs) > 0 {
+			// Only the sender's own, not yet sent uploads to this thread can be attached
+			if err := tx.Where("id IN ? AND thread_id = ? AND uploader_id = ? AND message_id IS NULL",
+				input.AttachmentIDs, thread.ID, userID).Find(&attachments).Error; err != nil {
+				return err
+			}
+			if len(attachments) != len(input.AttachmentIDs) {
+				return errInvalidAttachments
+			}
+		}
+
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+
+		if len(attachments) > 0 {
+			return tx.Model(&models.ChatAttachment{}).Where("id IN ?", input.AttachmentIDs).
+				Update("message_id", message.ID).Error
+		}
+		return nil
+	})
+	if err == errInvalidAttachments {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
 		return
 	}
 
-	// Update thread
-	thread.LastMessage = input.Content
-	thread.UpdatedAt = message.CreatedAt
-	config.DB.Save(&thread)
+	for i := range attachments {
+		attachments[i].MessageID = &message.ID
+	}
+	message.Attachments = attachments
 
-	// Notify other participant
-	recipientID := thread.Participant2ID
-	if userID == thread.Participant2ID {
-		recipientID = thread.Participant1ID
+	cc.publishChatMessage(thread, message, flagged)
+	cc.notifyChatRecipients(thread.ID, userID, messagePreview(message))
+
+	newContactMasker().message(&message)
+	c.JSON(http.StatusCreated, message)
+}
+
+// canPostToThread checks that a participant may send messages to the thread.
+// It writes the error response itself.
+func canPostToThread(c *gin.Context, thread models.ChatThread, participant models.ChatParticipant) bool {
+	if participant.Role == models.ParticipantRoleObserver {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Observers cannot send messages"})
+		return false
 	}
 
-	// Preload thread details for the broadcast
-	config.DB.Preload("Participant1").Preload("Participant2").Preload("Property").First(&thread, thread.ID)
+	// In one-to-one threads a block in either direction stops the conversation;
+	// in groups only people who blocked the sender are shielded from them
+	var recipients []uint
+	for _, id := range threadParticipantIDs(thread.ID) {
+		if id != participant.UserID {
+			recipients = append(recipients, id)
+		}
+	}
+	if (!thread.IsGroup && isBlockedBetween(participant.UserID, recipients...)) || (thread.IsGroup && isBlockedBy(participant.UserID, recipients)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot message this user"})
+		return false
+	}
+	return true
+}
 
-	// Notify recipient
-	cc.Hub.BroadcastToUser(recipientID, gin.H{
-		"type":    "NEW_MESSAGE",
-		"thread":  thread,
-		"message": message,
-	})
+// publishChatMessage updates the thread after a message has been stored and
+// pushes it to the participants. Offline notifications are left to the caller.
+func (cc *ChatController) publishChatMessage(thread models.ChatThread, message models.ChatMessage, flagged bool) {
+	if flagged {
+		flagContactSharing("message", message.ID)
+	}
+
+	thread.LastMessage = messagePreview(message)
+	thread.UpdatedAt = message.CreatedAt
+	config.DB.Save(&thread)
+	reviveThread(thread.ID)
 
-	// Notify sender's other tabs
-	cc.Hub.BroadcastToUser(userID, gin.H{
+	// Preload thread details for the broadcast
+	config.DB.Preload("Participants.User").Preload("Property").First(&thread, thread.ID)
+	aliases := loadInquiryAliases(thread.ID)
+	aliases.thread(&thread)
+	aliases.message(&message)
+	masker := newContactMasker()
+	masker.thread(&thread)
+	masker.message(&message)
+
+	// Notify every participant, including the sender's other tabs
+	cc.broadcastToThread(thread.ID, gin.H{
 		"type":    "NEW_MESSAGE",
 		"thread":  thread,
 		"message": message,
 	})
-
-	c.JSON(http.StatusCreated, message)
 }
 
 func (cc *ChatController) EditMessage(c *gin.Context) {
@@ -309,6 +789,9 @@ func (cc *ChatController) EditMessage(c *gin.Context) {
 		return
 	}
 
+	// Edits go through the same contact filter as new messages
+	flagged := contactFilterFlags(input.Content, threadContactsConsented(message.ThreadID))
+
 	now := time.Now()
 	message.Content = input.Content
 	message.IsEdited = true
@@ -319,24 +802,15 @@ func (cc *ChatController) EditMessage(c *gin.Context) {
 		return
 	}
 
-	// Notify all participants in the thread
-	var thread models.ChatThread
-	config.DB.First(&thread, message.ThreadID)
-
-	recipientID := thread.Participant2ID
-	if userID == thread.Participant2ID {
-		recipientID = thread.Participant1ID
+	if flagged {
+		flagContactSharing("message", message.ID)
 	}
+	newContactMasker().message(&message)
 
-	cc.Hub.BroadcastToUser(recipientID, gin.H{
-		"type":       "MESSAGE_EDITED",
-		"message_id": message.ID,
-		"content":    message.Content,
-		"edited_at":  message.EditedAt,
-	})
-
-	cc.Hub.BroadcastToUser(userID, gin.H{
+	// Notify all participants in the thread
+	cc.broadcastToThread(message.ThreadID, gin.H{
 		"type":       "MESSAGE_EDITED",
+		"thread_id":  message.ThreadID,
 		"message_id": message.ID,
 		"content":    message.Content,
 		"edited_at":  message.EditedAt,
@@ -370,21 +844,9 @@ func (cc *ChatController) DeleteMessage(c *gin.Context) {
 	}
 
 	// Notify all participants in the thread
-	var thread models.ChatThread
-	config.DB.First(&thread, message.ThreadID)
-
-	recipientID := thread.Participant2ID
-	if userID == thread.Participant2ID {
-		recipientID = thread.Participant1ID
-	}
-
-	cc.Hub.BroadcastToUser(recipientID, gin.H{
-		"type":       "MESSAGE_DELETED",
-		"message_id": message.ID,
-	})
-
-	cc.Hub.BroadcastToUser(userID, gin.H{
+	cc.broadcastToThread(message.ThreadID, gin.H{
 		"type":       "MESSAGE_DELETED",
+		"thread_id":  message.ThreadID,
 		"message_id": message.ID,
 	})
 
@@ -393,50 +855,162 @@ func (cc *ChatController) DeleteMessage(c *gin.Context) {
 
 func (cc *ChatController) SearchMessages(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
-	query := c.Query("q")
+	query := strings.TrimSpace(c.Query("q"))
 
 	if query == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
 		return
 	}
 
-	// Get user's threads first
-	var threadIDs []uint
-	config.DB.Model(&models.ChatThread{}).
-		Where("participant1_id = ? OR participant2_id = ?", userID, userID).
-		Pluck("id", &threadIDs)
-
-	if len(threadIDs) == 0 {
-		c.JSON(http.StatusOK, []models.MessageSearchResult{})
-		return
+	page := 1
+	limit := 20
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 50 {
+			limit = l
+		}
 	}
 
-	// Search messages in user's threads
-	var results []models.MessageSearchResult
-	searchQuery := "%" + query + "%"
+	// Only ever search inside threads the caller participates in
+	search := config.DB.Table("chat_messages").
+		Joins("JOIN chat_threads ON chat_threads.id = chat_messages.thread_id").
+		Joins("JOIN chat_participants ON chat_participants.thread_id = chat_messages.thread_id AND chat_participants.user_id = ?", userID).
+		Where("chat_messages.is_deleted = ? AND chat_messages.id > chat_participants.cleared_up_to_id", false).
+		Where("to_tsvector('simple', chat_messages.content) @@ websearch_to_tsquery('simple', ?)", query)
 
-	err := config.DB.Table("chat_messages").
-		Select(`
-			chat_messages.*, 
-			chat_threads.*,
-			CASE 
-				WHAT chat_threads.participant1_id = ? THEN chat_threads.participant2_id
-				ELSE chat_threads.participant1_id
-			END as other_participant_id
-		`, userID).
-		Joins("JOIN chat_threads ON chat_messages.thread_id = chat_threads.id").
-		Where("chat_messages.thread_id IN ? AND chat_messages.content LIKE ? AND chat_messages.is_deleted = ?",
-			threadIDs, searchQuery, false).
-		Order("chat_messages.created_at DESC").
-		Limit(50).
-		Scan(&results).Error
+	if threadID := c.Query("thread_id"); threadID != "" {
+		search = search.Where("chat_messages.thread_id = ?", threadID)
+	}
+	if senderID := c.Query("sender_id"); senderID != "" {
+		search = search.Where("chat_messages.sender_id = ?", senderID)
+	}
+	if propertyID := c.Query("property_id"); propertyID != "" {
+		search = search.Where("chat_threads.property_id = ?", propertyID)
+	}
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be formatted as YYYY-MM-DD"})
+			return
+		}
+		search = search.Where("chat_messages.created_at >= ?", from)
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be formatted as YYYY-MM-DD"})
+			return
+		}
+		// Inclusive of the whole "to" day
+		search = search.Where("chat_messages.created_at < ?", to.AddDate(0, 0, 1))
+	}
 
+	var total int64
+	if err := search.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
+		return
+	}
+
+	// Matches are delimited with control characters and the snippet is
+	// HTML-escaped before they become <mark> tags, so markup typed into a
+	// message is never returned as markup
+	var hits []struct {
+		ID        uint
+		ThreadID  uint
+		Highlight string
+	}
+	err := search.Session(&gorm.Session{}).
+		Select(`chat_messages.id, chat_messages.thread_id,
+			ts_headline('simple', translate(chat_messages.content, ?, ''), websearch_to_tsquery('simple', ?),
+				?) AS highlight`,
+			highlightStart+highlightStop, query,
+			"StartSel="+highlightStart+", StopSel="+highlightStop+", MinWords=5, MaxWords=20, MaxFragments=2, FragmentDelimiter= ... ").
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "ts_rank(to_tsvector('simple', chat_messages.content), websearch_to_tsquery('simple', ?)) DESC, chat_messages.created_at DESC",
+			Vars: []interface{}{query},
+		}}).
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Scan(&hits).Error
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
 		return
 	}
 
-	c.JSON(http.StatusOK, results)
+	results := []models.MessageSearchResult{}
+	if len(hits) > 0 {
+		messageIDs := make([]uint, len(hits))
+		threadIDs := make([]uint, len(hits))
+		for i, hit := range hits {
+			messageIDs[i] = hit.ID
+			threadIDs[i] = hit.ThreadID
+		}
+
+		var messages []models.ChatMessage
+		config.DB.Preload("Sender").Preload("Attachments").Where("id IN ?", messageIDs).Find(&messages)
+		var threads []models.ChatThread
+		config.DB.Preload("Participants.User").Preload("Property").
+			Where("id IN ?", threadIDs).Find(&threads)
+
+		messageByID := make(map[uint]models.ChatMessage, len(messages))
+		for _, msg := range messages {
+			messageByID[msg.ID] = msg
+		}
+		threadByID := make(map[uint]models.ChatThread, len(threads))
+		for _, thread := range threads {
+			threadByID[thread.ID] = thread
+		}
+
+		// Keep the ranking order from the search query
+		aliases := loadInquiryAliases(threadIDs...)
+		masker := newContactMasker()
+		for _, hit := range hits {
+			message, thread := messageByID[hit.ID], threadByID[hit.ThreadID]
+			highlight := hit.Highlight
+			if masker.hides(hit.ThreadID) {
+				// A snippet can cut a phone number short of the pattern, so
+				// messages with contact details show their masked text unmarked
+				if masked, found := maskContactDetails(message.Content); found {
+					highlight = masked
+				}
+			}
+			aliases.message(&message)
+			aliases.thread(&thread)
+			masker.message(&message)
+			masker.thread(&thread)
+			results = append(results, models.MessageSearchResult{
+				Message:   message,
+				Thread:    thread,
+				Highlight: highlightHTML(highlight),
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// Delimiters ts_headline puts around matches in search snippets
+const (
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+// highlightHTML escapes a search snippet and turns the match delimiters into
+// <mark> tags.
+func highlightHTML(snippet string) string {
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(html.EscapeString(snippet))
 }
 
 func (cc *ChatController) UpdateTypingStatus(c *gin.Context) {
@@ -452,41 +1026,16 @@ func (cc *ChatController) UpdateTypingStatus(c *gin.Context) {
 		return
 	}
 
-	var thread models.ChatThread
-	if err := config.DB.First(&thread, threadID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
-		return
-	}
-
-	if thread.Participant1ID != userID && thread.Participant2ID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	thread, _, ok := loadThreadForParticipant(c, threadID, userID)
+	if !ok {
 		return
 	}
 
-	// Update typing status
-	if thread.Participant1ID == userID {
-		thread.IsTyping1 = input.IsTyping
-	} else {
-		thread.IsTyping2 = input.IsTyping
-	}
-
-	if err := config.DB.Save(&thread).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update typing status"})
+	// Typing state lives only in the hub and expires on its own
+	if !cc.Hub.Typing.Set(thread.ID, userID, threadParticipantIDs(thread.ID), input.IsTyping) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Typing updates are rate limited"})
 		return
 	}
 
-	// Notify other participant
-	recipientID := thread.Participant2ID
-	if userID == thread.Participant2ID {
-		recipientID = thread.Participant1ID
-	}
-
-	cc.Hub.BroadcastToUser(recipientID, gin.H{
-		"type":      "TYPING_STATUS",
-		"thread_id": thread.ID,
-		"user_id":   userID,
-		"is_typing": input.IsTyping,
-	})
-
 	c.JSON(http.StatusOK, gin.H{"status": "updated"})
 }
@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/rbac"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var validParticipantRoles = map[string]bool{
+	models.ParticipantRoleOwner:    true,
+	models.ParticipantRoleBroker:   true,
+	models.ParticipantRoleSeeker:   true,
+	models.ParticipantRoleObserver: true,
+}
+
+// AddParticipant adds a user to a thread. Owners and brokers in the thread can
+// add people; admins can join or add anyone, typically as an observer.
+func (cc *ChatController) AddParticipant(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	isAdmin := rbac.Can(userID, rbac.PermChatModerate)
+
+	var input struct {
+		UserID uint   `json:"user_id" binding:"required"`
+		Role   string `json:"role"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var thread models.ChatThread
+	if err := config.DB.First(&thread, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+
+	if !isAdmin {
+		var actor models.ChatParticipant
+		if err := config.DB.Where("thread_id = ? AND user_id = ?", thread.ID, userID).First(&actor).Error; err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		if actor.Role != models.ParticipantRoleOwner && actor.Role != models.ParticipantRoleBroker {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only owners and brokers can add participants"})
+			return
+		}
+	}
+
+	role := input.Role
+	if role == "" {
+		role = models.ParticipantRoleSeeker
+		if isAdmin && input.UserID == userID {
+			role = models.ParticipantRoleObserver
+		}
+	}
+	if !validParticipantRoles[role] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant role"})
+		return
+	}
+	if role == models.ParticipantRoleObserver && !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can add observers"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, input.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if isBlockedBetween(userID, user.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot add this user"})
+		return
+	}
+
+	var existing int64
+	config.DB.Model(&models.ChatParticipant{}).Where("thread_id = ? AND user_id = ?", thread.ID, user.ID).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a participant"})
+		return
+	}
+
+	participant := models.ChatParticipant{
+		ThreadID: thread.ID,
+		UserID:   user.ID,
+		Role:     role,
+		JoinedAt: time.Now(),
+	}
+	if err := config.DB.Create(&participant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add participant"})
+		return
+	}
+	participant.User = user
+
+	// A third person turns a one-to-one conversation into a group
+	if !thread.IsGroup {
+		config.DB.Model(&thread).Update("is_group", true)
+	}
+
+	participant.User.Name = threadDisplayName(thread.ID, user)
+	content := participant.User.Name + " joined the conversation"
+	if input.UserID != userID {
+		var actor models.User
+		config.DB.First(&actor, userID)
+		content = threadDisplayName(thread.ID, actor) + " added " + participant.User.Name
+	}
+	cc.postSystemMessage(thread.ID, userID, content)
+
+	c.JSON(http.StatusCreated, participant)
+}
+
+// RemoveParticipant removes a user from a thread. Anyone may leave; owners,
+// brokers and admins may remove others.
+func (cc *ChatController) RemoveParticipant(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	isAdmin := rbac.Can(userID, rbac.PermChatModerate)
+
+	var thread models.ChatThread
+	if err := config.DB.First(&thread, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+
+	var target models.ChatParticipant
+	if err := config.DB.Preload("User").Where("thread_id = ? AND user_id = ?", thread.ID, c.Param("userId")).
+		First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Participant not found"})
+		return
+	}
+
+	if target.UserID != userID && !isAdmin {
+		var actor models.ChatParticipant
+		if err := config.DB.Where("thread_id = ? AND user_id = ?", thread.ID, userID).First(&actor).Error; err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		if actor.Role != models.ParticipantRoleOwner && actor.Role != models.ParticipantRoleBroker {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only owners and brokers can remove participants"})
+			return
+		}
+	}
+
+	if err := config.DB.Delete(&target).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove participant"})
+		return
+	}
+
+	targetName := threadDisplayName(thread.ID, target.User)
+	content := targetName + " left the conversation"
+	if target.UserID != userID {
+		var actor models.User
+		config.DB.First(&actor, userID)
+		content = threadDisplayName(thread.ID, actor) + " removed " + targetName
+	}
+	cc.postSystemMessage(thread.ID, userID, content)
+
+	// The removed user no longer receives thread broadcasts, so tell them directly
+	cc.Hub.BroadcastToUser(target.UserID, gin.H{
+		"type":      "PARTICIPANT_REMOVED",
+		"thread_id": thread.ID,
+		"user_id":   target.UserID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"status": "removed"})
+}
+
+// ShareContact reveals the caller's phone number to the rest of the thread and
+// records their consent. Once every party has shared, messages are no longer
+// filtered for contact details.
+func (cc *ChatController) ShareContact(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	thread, participant, ok := loadThreadForParticipant(c, c.Param("id"), userID)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.Phone == "" || !user.PhoneVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verify a phone number on your profile before sharing it"})
+		return
+	}
+
+	if participant.ContactSharedAt == nil {
+		now := time.Now()
+		if err := config.DB.Model(&participant).Update("contact_shared_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share contact"})
+			return
+		}
+	}
+
+	// System messages bypass the contact filter
+	cc.postSystemMessage(thread.ID, userID, threadDisplayName(thread.ID, user)+" shared their phone number: "+user.Phone)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "shared",
+		"all_shared": threadContactsConsented(thread.ID),
+	})
+}
+
+// postSystemMessage records an event in the thread's history and pushes it to
+// every current participant.
+func (cc *ChatController) postSystemMessage(threadID uint, actorID uint, content string) {
+	message := models.ChatMessage{
+		ThreadID:  threadID,
+		SenderID:  actorID,
+		Type:      models.MessageTypeSystem,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}
+	if err := config.DB.Create(&message).Error; err != nil {
+		return
+	}
+
+	config.DB.Model(&models.ChatThread{}).Where("id = ?", threadID).
+		Updates(map[string]interface{}{"last_message": content, "updated_at": message.CreatedAt})
+
+	var thread models.ChatThread
+	config.DB.Preload("Participants.User").Preload("Property").First(&thread, threadID)
+	loadInquiryAliases(threadID).thread(&thread)
+	newContactMasker().thread(&thread)
+
+	cc.broadcastToThread(threadID, gin.H{
+		"type":    "NEW_MESSAGE",
+		"thread":  thread,
+		"message": message,
+	})
+}
+
+// loadThreadForParticipant fetches a thread together with the caller's
+// membership of it. It writes the error response itself.
+func loadThreadForParticipant(c *gin.Context, threadID interface{}, userID uint) (models.ChatThread, models.ChatParticipant, bool) {
+	var thread models.ChatThread
+	var participant models.ChatParticipant
+
+	if err := config.DB.First(&thread, threadID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return thread, participant, false
+	}
+
+	if err := config.DB.Where("thread_id = ? AND user_id = ?", thread.ID, userID).First(&participant).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return thread, participant, false
+	}
+
+	return thread, participant, true
+}
+
+// threadParticipantIDs lists the user IDs currently in a thread.
+func threadParticipantIDs(threadID uint) []uint {
+	var userIDs []uint
+	config.DB.Model(&models.ChatParticipant{}).Where("thread_id = ?", threadID).Pluck("user_id", &userIDs)
+	return userIDs
+}
+
+// broadcastToThread sends a WebSocket event to every participant of a thread,
+// including the caller's other tabs.
+func (cc *ChatController) broadcastToThread(threadID uint, message interface{}) {
+	cc.Hub.BroadcastToThread(threadID, threadParticipantIDs(threadID), message)
+}
+
+// participantRoleFor picks a user's default role in a thread about a property.
+func participantRoleFor(userID uint, property *models.Property) string {
+	if property != nil && property.OwnerID == userID {
+		if property.PostedAs == "Broker" {
+			return models.ParticipantRoleBroker
+		}
+		return models.ParticipantRoleOwner
+	}
+	return models.ParticipantRoleSeeker
+}
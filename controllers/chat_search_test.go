@@ -0,0 +1,11 @@
+package controllers
+
+import "testing"
+
+func TestHighlightHTML(t *testing.T) {
+	snippet := `call me <img src=x onerror="alert(1)"> about the ` + highlightStart + "flat" + highlightStop + " & garden"
+	want := `call me &lt;img src=x onerror=&#34;alert(1)&#34;&gt; about the <mark>flat</mark> &amp; garden`
+	if got := highlightHTML(snippet); got != want {
+		t.Errorf("highlightHTML\n got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/notify"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ArchiveThread hides a thread from the caller's main list until a new message arrives
+func (cc *ChatController) ArchiveThread(c *gin.Context) {
+	var input struct {
+		Archived bool `json:"archived"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updateThreadState(c, map[string]interface{}{"is_archived": input.Archived})
+}
+
+// MuteThread silences notifications for a thread, optionally for a number of hours.
+// WebSocket events are still delivered so open clients stay in sync.
+func (cc *ChatController) MuteThread(c *gin.Context) {
+	var input struct {
+		Muted bool `json:"muted"`
+		Hours int  `json:"hours"` // Zero mutes indefinitely
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var mutedUntil *time.Time
+	if input.Muted && input.Hours > 0 {
+		until := time.Now().Add(time.Duration(input.Hours) * time.Hour)
+		mutedUntil = &until
+	}
+
+	updateThreadState(c, map[string]interface{}{"is_muted": input.Muted, "muted_until": mutedUntil})
+}
+
+// PinThread keeps a thread at the top of the caller's list
+func (cc *ChatController) PinThread(c *gin.Context) {
+	var input struct {
+		Pinned bool `json:"pinned"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var pinnedAt *time.Time
+	if input.Pinned {
+		now := time.Now()
+		pinnedAt = &now
+	}
+
+	updateThreadState(c, map[string]interface{}{"is_pinned": input.Pinned, "pinned_at": pinnedAt})
+}
+
+// DeleteThreadForMe removes a thread and its current history from the caller's
+// view only. The thread comes back, without the old messages, if someone writes again.
+func (cc *ChatController) DeleteThreadForMe(c *gin.Context) {
+	var lastMessageID uint
+	config.DB.Model(&models.ChatMessage{}).Where("thread_id = ?", c.Param("id")).
+		Select("COALESCE(MAX(id), 0)").Scan(&lastMessageID)
+
+	updateThreadState(c, map[string]interface{}{
+		"is_deleted_for_me":    true,
+		"cleared_up_to_id":     lastMessageID,
+		"is_pinned":            false,
+		"pinned_at":            nil,
+		"last_read_message_id": lastMessageID,
+	})
+}
+
+func updateThreadState(c *gin.Context, updates map[string]interface{}) {
+	userID := c.MustGet("userID").(uint)
+
+	_, participant, ok := loadThreadForParticipant(c, c.Param("id"), userID)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Model(&participant).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update thread"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"thread_id":   participant.ThreadID,
+		"is_archived": participant.IsArchived,
+		"is_muted":    participant.MutedAt(time.Now()),
+		"is_pinned":   participant.IsPinned,
+	})
+}
+
+// reviveThread brings an archived or deleted-for-me thread back into every
+// participant's list when a new message arrives.
+func reviveThread(threadID uint) {
+	config.DB.Model(&models.ChatParticipant{}).
+		Where("thread_id = ? AND (is_archived = ? OR is_deleted_for_me = ?)", threadID, true, true).
+		Updates(map[string]interface{}{"is_archived": false, "is_deleted_for_me": false})
+}
+
+// notifyChatRecipients creates in-app notifications for a new message for
+// recipients who are offline and have not muted the thread.
+func (cc *ChatController) notifyChatRecipients(threadID uint, senderID uint, content string) {
+	var participants []models.ChatParticipant
+	config.DB.Where("thread_id = ? AND user_id != ?", threadID, senderID).Find(&participants)
+
+	var sender models.User
+	config.DB.First(&sender, senderID)
+	content = newContactMasker().text(threadID, content)
+
+	now := time.Now()
+	for _, p := range participants {
+		if p.MutedAt(now) || cc.Hub.IsOnline(p.UserID) {
+			continue
+		}
+		notify.Send(models.Notification{
+			UserID:     p.UserID,
+			Content:    "New message from " + threadDisplayName(threadID, sender) + ": " + content,
+			Category:   models.NotificationCategoryChat,
+			EntityType: "chat_thread",
+			EntityID:   &threadID,
+			Data:       models.JSONMap{"sender_id": senderID},
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"regexp"
+)
+
+// Modes for the "chat_contact_filter" site setting
+const (
+	contactFilterMask = "mask" // Hide contact details from readers until everyone consents (default)
+	contactFilterFlag = "flag" // Deliver as written but queue the message for moderation
+	contactFilterOff  = "off"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	linkPattern  = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9\-]+\.(?:com|in|net|org|co|io|me|info|biz|ly)(?:/\S*)?\b`)
+	// Ten or more digits, optionally separated by spaces, dots or dashes
+	phonePattern = regexp.MustCompile(`\+?\d(?:[\s\-.]?\d){9,}`)
+)
+
+func contactFilterMode() string {
+	var cfg models.SiteConfig
+	if err := config.DB.Where("key = ?", "chat_contact_filter").First(&cfg).Error; err != nil {
+		return contactFilterMask
+	}
+	switch cfg.Value {
+	case contactFilterFlag, contactFilterOff:
+		return cfg.Value
+	}
+	return contactFilterMask
+}
+
+// maskContactDetails hides emails, links and phone numbers in content and
+// reports whether anything was found.
+func maskContactDetails(content string) (string, bool) {
+	masked := emailPattern.ReplaceAllString(content, "[email hidden]")
+	masked = linkPattern.ReplaceAllString(masked, "[link hidden]")
+	masked = phonePattern.ReplaceAllString(masked, "[phone hidden]")
+	return masked, masked != content
+}
+
+// contactFilterFlags reports whether a message should be flagged for review.
+// Messages are stored as written; in mask mode contact details are hidden when
+// they are read instead (see contactMasker). Nothing is flagged once every
+// party has agreed to share contact details.
+func contactFilterFlags(content string, consented bool) bool {
+	if consented || contactFilterMode() != contactFilterFlag {
+		return false
+	}
+	_, found := maskContactDetails(content)
+	return found
+}
+
+// contactMasker hides contact details in messages read from threads where not
+// everyone has agreed to share them yet, so the original text shows once they
+// have. A masker caches consent per thread and serves a single request.
+type contactMasker struct {
+	mode      string
+	consented map[uint]bool
+}
+
+func newContactMasker() *contactMasker {
+	return &contactMasker{mode: contactFilterMode(), consented: make(map[uint]bool)}
+}
+
+// hides reports whether contact details are masked in the thread.
+func (m *contactMasker) hides(threadID uint) bool {
+	if m.mode != contactFilterMask {
+		return false
+	}
+	consented, ok := m.consented[threadID]
+	if !ok {
+		consented = threadContactsConsented(threadID)
+		m.consented[threadID] = consented
+	}
+	return !consented
+}
+
+func (m *contactMasker) text(threadID uint, content string) string {
+	if !m.hides(threadID) {
+		return content
+	}
+	masked, _ := maskContactDetails(content)
+	return masked
+}
+
+// message masks a message and the message it replies to. System messages,
+// such as a participant sharing their phone number, are left alone.
+func (m *contactMasker) message(msg *models.ChatMessage) {
+	if msg.Type != models.MessageTypeSystem {
+		msg.Content = m.text(msg.ThreadID, msg.Content)
+	}
+	if msg.ReplyTo != nil {
+		m.message(msg.ReplyTo)
+	}
+}
+
+func (m *contactMasker) messages(msgs []models.ChatMessage) {
+	for i := range msgs {
+		m.message(&msgs[i])
+	}
+}
+
+// thread masks a thread's last message preview and any loaded messages.
+func (m *contactMasker) thread(thread *models.ChatThread) {
+	thread.LastMessage = m.text(thread.ID, thread.LastMessage)
+	m.messages(thread.Messages)
+}
+
+// inquiry masks an inquiry's opening message and its loaded conversation.
+func (m *contactMasker) inquiry(inquiry *models.Inquiry) {
+	if inquiry.ChatThreadID != nil {
+		inquiry.InitialMessage = m.text(*inquiry.ChatThreadID, inquiry.InitialMessage)
+	} else if m.mode == contactFilterMask {
+		// Only before MigrateInquiryThreads has run; there is no consent to check
+		inquiry.InitialMessage, _ = maskContactDetails(inquiry.InitialMessage)
+	}
+	if inquiry.ChatThread != nil {
+		m.thread(inquiry.ChatThread)
+	}
+}
+
+// flagContactSharing files a system report so moderators can review a message
+// that shared contact details before consent.
+func flagContactSharing(targetType string, targetID uint) {
+	report := models.Report{
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     models.ReportReasonContactSharing,
+		Details:    "Automatically flagged: contact details shared before both parties consented",
+		Status:     models.ReportStatusPending,
+	}
+	config.DB.Create(&report)
+}
+
+// threadContactsConsented reports whether every non-observer participant in a
+// thread has chosen to share their contact details.
+func threadContactsConsented(threadID uint) bool {
+	var pending int64
+	config.DB.Model(&models.ChatParticipant{}).
+		Where("thread_id = ? AND role != ? AND contact_shared_at IS NULL", threadID, models.ParticipantRoleObserver).
+		Count(&pending)
+	return pending == 0
+}
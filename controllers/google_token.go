@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const defaultGoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// googleClaims are the ID token fields sign-in relies on.
+type googleClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// jwksCache holds the signing keys published at a JWKS URL. Keys are refreshed
+// when the response's max-age runs out, or early when a token names a key we
+// have not seen (Google rotates keys), at most once a minute.
+type jwksCache struct {
+	URL    string
+	Client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	expiresAt   time.Time
+	lastFetchAt time.Time
+}
+
+var googleKeys = &jwksCache{Client: &http.Client{Timeout: 10 * time.Second}}
+
+func (j *jwksCache) key(kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	stale := time.Now().After(j.expiresAt)
+	_, known := j.keys[kid]
+	if stale || (!known && time.Since(j.lastFetchAt) > time.Minute) {
+		if err := j.fetch(); err != nil && j.keys == nil {
+			return nil, err
+		}
+	}
+
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (j *jwksCache) fetch() error {
+	url := j.URL
+	if url == "" {
+		url = envOrDefault("GOOGLE_JWKS_URL", defaultGoogleJWKSURL)
+	}
+	j.lastFetchAt = time.Now()
+
+	resp, err := j.Client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks endpoint returned %s", resp.Status)
+	}
+
+	var body struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range body.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks endpoint returned no RSA keys")
+	}
+
+	j.keys = keys
+	j.expiresAt = time.Now().Add(maxAge(resp.Header.Get("Cache-Control"), time.Hour))
+	return nil
+}
+
+// maxAge reads max-age from a Cache-Control header
+func maxAge(cacheControl string, fallback time.Duration) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		if v, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return fallback
+}
+
+// verifyGoogleIDToken checks the token's signature against Google's keys and
+// that it was issued by Google, for this app (GOOGLE_CLIENT_ID, comma
+// separated for several clients), has not expired and carries a verified email.
+func verifyGoogleIDToken(idToken string) (*googleClaims, error) {
+	var audiences []string
+	for _, id := range strings.Split(os.Getenv("GOOGLE_CLIENT_ID"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			audiences = append(audiences, id)
+		}
+	}
+	if len(audiences) == 0 {
+		return nil, errors.New("GOOGLE_CLIENT_ID is not configured")
+	}
+
+	claims := &googleClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return googleKeys.key(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithAudience(audiences...),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	issuers := strings.Split(envOrDefault("GOOGLE_ISSUERS", "https://accounts.google.com,accounts.google.com"), ",")
+	validIssuer := false
+	for _, iss := range issuers {
+		validIssuer = validIssuer || strings.TrimSpace(iss) == claims.Issuer
+	}
+	if !validIssuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	if !claims.EmailVerified {
+		return nil, errors.New("google account email is not verified")
+	}
+
+	return claims, nil
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
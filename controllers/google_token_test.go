@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "test-client.apps.googleusercontent.com"
+
+// fakeJWKS serves whichever keys it currently holds and counts fetches.
+type fakeJWKS struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int
+}
+
+func (f *fakeJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetches++
+
+	var body struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, key := range f.keys {
+		body.Keys = append(body.Keys, map[string]string{
+			"kid": kid,
+			"kty": "RSA",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeJWKS) setKeys(keys map[string]*rsa.PrivateKey) {
+	f.mu.Lock()
+	f.keys = keys
+	f.mu.Unlock()
+}
+
+func (f *fakeJWKS) fetchCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetches
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// setupGoogleKeys points token verification at a local JWKS endpoint for the test.
+func setupGoogleKeys(t *testing.T, keys map[string]*rsa.PrivateKey) *fakeJWKS {
+	t.Helper()
+	jwks := &fakeJWKS{keys: keys}
+	server := httptest.NewServer(jwks)
+	t.Cleanup(server.Close)
+
+	previous := googleKeys
+	googleKeys = &jwksCache{URL: server.URL, Client: server.Client()}
+	t.Cleanup(func() { googleKeys = previous })
+
+	t.Setenv("GOOGLE_CLIENT_ID", testClientID)
+	t.Setenv("GOOGLE_ISSUERS", "")
+	return jwks
+}
+
+func validGoogleClaims() googleClaims {
+	now := time.Now()
+	return googleClaims{
+		Email:         "asha@example.com",
+		EmailVerified: true,
+		Name:          "Asha",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://accounts.google.com",
+			Subject:   "110169484474386276334",
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func signGoogleToken(t *testing.T, key *rsa.PrivateKey, kid string, claims googleClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyGoogleIDToken(t *testing.T) {
+	key := newRSAKey(t)
+	setupGoogleKeys(t, map[string]*rsa.PrivateKey{"k1": key})
+
+	claims, err := verifyGoogleIDToken(signGoogleToken(t, key, "k1", validGoogleClaims()))
+	if err != nil {
+		t.Fatalf("valid token refused: %v", err)
+	}
+	if claims.Subject != "110169484474386276334" || claims.Email != "asha@example.com" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestVerifyGoogleIDTokenRejects(t *testing.T) {
+	key := newRSAKey(t)
+	other := newRSAKey(t)
+	setupGoogleKeys(t, map[string]*rsa.PrivateKey{"k1": key})
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{"bad signature", func() string {
+			return signGoogleToken(t, other, "k1", validGoogleClaims())
+		}},
+		{"tampered payload", func() string {
+			token := signGoogleToken(t, key, "k1", validGoogleClaims())
+			forged := validGoogleClaims()
+			forged.Email = "admin@rjg.com"
+			payload, _ := json.Marshal(forged)
+			parts := strings.Split(token, ".")
+			return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+		}},
+		{"wrong audience", func() string {
+			claims := validGoogleClaims()
+			claims.Audience = jwt.ClaimStrings{"someone-else.apps.googleusercontent.com"}
+			return signGoogleToken(t, key, "k1", claims)
+		}},
+		{"wrong issuer", func() string {
+			claims := validGoogleClaims()
+			claims.Issuer = "https://evil.example.com"
+			return signGoogleToken(t, key, "k1", claims)
+		}},
+		{"expired", func() string {
+			claims := validGoogleClaims()
+			claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(-3 * time.Hour))
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Hour))
+			return signGoogleToken(t, key, "k1", claims)
+		}},
+		{"no expiry", func() string {
+			claims := validGoogleClaims()
+			claims.ExpiresAt = nil
+			return signGoogleToken(t, key, "k1", claims)
+		}},
+		{"unverified email", func() string {
+			claims := validGoogleClaims()
+			claims.EmailVerified = false
+			return signGoogleToken(t, key, "k1", claims)
+		}},
+		{"missing subject", func() string {
+			claims := validGoogleClaims()
+			claims.Subject = ""
+			return signGoogleToken(t, key, "k1", claims)
+		}},
+		{"HS256", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, validGoogleClaims())
+			token.Header["kid"] = "k1"
+			signed, _ := token.SignedString([]byte("secret"))
+			return signed
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verifyGoogleIDToken(tt.token()); err == nil {
+				t.Error("expected the token to be refused")
+			}
+		})
+	}
+}
+
+func TestVerifyGoogleIDTokenKeyRotation(t *testing.T) {
+	oldKey := newRSAKey(t)
+	newKey := newRSAKey(t)
+	jwks := setupGoogleKeys(t, map[string]*rsa.PrivateKey{"old": oldKey})
+
+	if _, err := verifyGoogleIDToken(signGoogleToken(t, oldKey, "old", validGoogleClaims())); err != nil {
+		t.Fatalf("token with current key refused: %v", err)
+	}
+
+	// Google publishes a new key and retires the old one
+	jwks.setKeys(map[string]*rsa.PrivateKey{"new": newKey})
+	token := signGoogleToken(t, newKey, "new", validGoogleClaims())
+
+	// Unknown kids trigger at most one refetch a minute
+	fetches := jwks.fetchCount()
+	if _, err := verifyGoogleIDToken(token); err == nil {
+		t.Fatal("expected the new key to be unknown until the refetch window passes")
+	}
+	if jwks.fetchCount() != fetches {
+		t.Errorf("refetched keys %d times within a minute", jwks.fetchCount()-fetches)
+	}
+
+	googleKeys.lastFetchAt = time.Now().Add(-2 * time.Minute)
+	if _, err := verifyGoogleIDToken(token); err != nil {
+		t.Fatalf("token with rotated key refused: %v", err)
+	}
+	if _, err := verifyGoogleIDToken(signGoogleToken(t, oldKey, "old", validGoogleClaims())); err == nil {
+		t.Error("token with a retired key accepted")
+	}
+}
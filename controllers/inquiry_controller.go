@@ -5,11 +5,17 @@ import (
 	"net/http"
 	"realstate-backend/config"
 	"realstate-backend/models"
+	"realstate-backend/notify"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func CreateInquiry(c *gin.Context) {
+// CreateInquiry opens an inquiry about a property. The conversation itself is
+// a property-scoped chat thread between the seeker and the owner, so replies get
+// realtime delivery, read receipts and editing.
+func (cc *ChatController) CreateInquiry(c *gin.Context) {
 	var input struct {
 		PropertyID     uint    `json:"property_id" binding:"required"`
 		InitialMessage string  `json:"initial_message" binding:"required"`
@@ -31,6 +37,22 @@ func CreateInquiry(c *gin.Context) {
 		return
 	}
 
+	if property.OwnerID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot inquire about your own property"})
+		return
+	}
+
+	if isBlockedBetween(userID, property.OwnerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot contact this owner"})
+		return
+	}
+
+	// An earlier chat or inquiry about the same property continues in the same thread
+	thread, found := findDirectThread(userID, property.OwnerID, &property.ID)
+
+	flagged := contactFilterFlags(input.InitialMessage, found && threadContactsConsented(thread.ID))
+	now := time.Now()
+
 	inquiry := models.Inquiry{
 		PropertyID:     input.PropertyID,
 		SeekerID:       userID,
@@ -40,20 +62,63 @@ func CreateInquiry(c *gin.Context) {
 		Budget:         input.Budget,
 		Status:         "Open",
 	}
+	message := models.ChatMessage{
+		SenderID:  userID,
+		Content:   input.InitialMessage,
+		CreatedAt: now,
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if !found {
+			thread = models.ChatThread{
+				PropertyID:  &property.ID,
+				CreatedByID: userID,
+				UpdatedAt:   now,
+			}
+			if err := tx.Create(&thread).Error; err != nil {
+				return err
+			}
 
-	if err := config.DB.Create(&inquiry).Error; err != nil {
+			participants := []models.ChatParticipant{
+				{ThreadID: thread.ID, UserID: userID, Role: participantRoleFor(userID, &property), JoinedAt: now},
+				{ThreadID: thread.ID, UserID: property.OwnerID, Role: participantRoleFor(property.OwnerID, &property), JoinedAt: now},
+			}
+			if err := tx.Create(&participants).Error; err != nil {
+				return err
+			}
+		}
+
+		inquiry.ChatThreadID = &thread.ID
+		if err := tx.Create(&inquiry).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&thread).Update("inquiry_id", inquiry.ID).Error; err != nil {
+			return err
+		}
+
+		message.ThreadID = thread.ID
+		return tx.Create(&message).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inquiry"})
 		return
 	}
 
-	// Create Notification for the owner
-	notification := models.Notification{
-		UserID:  property.OwnerID,
-		Content: "You have a new inquiry for " + property.Title,
-		Type:    "inquiry",
-	}
-	config.DB.Create(&notification)
+	thread.InquiryID = &inquiry.ID
+	cc.publishChatMessage(thread, message, flagged)
 
+	// Create Notification for the owner
+	notify.Send(models.Notification{
+		UserID:     property.OwnerID,
+		Content:    "You have a new inquiry for " + property.Title,
+		Category:   models.NotificationCategoryInquiry,
+		EntityType: "inquiry",
+		EntityID:   &inquiry.ID,
+		Data:       models.JSONMap{"property_id": property.ID, "chat_thread_id": thread.ID},
+	})
+
+	newContactMasker().inquiry(&inquiry)
 	c.JSON(http.StatusCreated, inquiry)
 }
 
@@ -76,7 +141,9 @@ func GetMyInquiries(c *gin.Context) {
 	}
 
 	// Anonymize names based on preference
+	masker := newContactMasker()
 	for i := range inquiries {
+		masker.inquiry(&inquiries[i])
 		// If I am the owner, anonymize the seeker if they want
 		if role == "owner" {
 			if inquiries[i].Seeker.PublicPreference == "Anonymized" {
@@ -93,12 +160,16 @@ func GetMyInquiries(c *gin.Context) {
 	c.JSON(http.StatusOK, inquiries)
 }
 
+// GetInquiryDetail returns an inquiry with its chat thread and messages. Newer
+// messages can be paged and synced through the chat endpoints.
 func GetInquiryDetail(c *gin.Context) {
 	id := c.Param("id")
 	userID := c.MustGet("userID").(uint)
 
 	var inquiry models.Inquiry
-	if err := config.DB.Preload("Property").Preload("Seeker").Preload("Owner").Preload("Messages.Sender").First(&inquiry, id).Error; err != nil {
+	if err := config.DB.Preload("Property").Preload("Seeker").Preload("Owner").
+		Preload("ChatThread.Participants.User").
+		First(&inquiry, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Inquiry not found"})
 		return
 	}
@@ -109,27 +180,137 @@ func GetInquiryDetail(c *gin.Context) {
 		return
 	}
 
+	// Same visibility as the chat message listing: no deleted messages and
+	// nothing before the caller cleared their history
+	if thread := inquiry.ChatThread; thread != nil {
+		var clearedUpTo uint
+		for _, p := range thread.Participants {
+			if p.UserID == userID {
+				clearedUpTo = p.ClearedUpToID
+			}
+		}
+		config.DB.Preload("Sender").Preload("Attachments").
+			Where("thread_id = ? AND is_deleted = ? AND id > ?", thread.ID, false, clearedUpTo).
+			Order("created_at ASC, id ASC").
+			Find(&thread.Messages)
+	}
+
 	// Anonymize thread participants
-	if inquiry.Seeker.PublicPreference == "Anonymized" {
-		inquiry.Seeker.Name = "Requester " + fmt.Sprint(inquiry.Seeker.ID+500)
+	inquiry.Seeker.Name = inquiryDisplayName(inquiry, inquiry.Seeker)
+	inquiry.Owner.Name = inquiryDisplayName(inquiry, inquiry.Owner)
+
+	if thread := inquiry.ChatThread; thread != nil {
+		inquiryAliases{thread.ID: inquiry}.thread(thread)
+	}
+	newContactMasker().inquiry(&inquiry)
+
+	c.JSON(http.StatusOK, inquiry)
+}
+
+// inquiryDisplayName is the name user goes by in an inquiry. Seekers and owners
+// who chose to stay anonymous appear under an alias.
+func inquiryDisplayName(inquiry models.Inquiry, user models.User) string {
+	if user.PublicPreference != "Anonymized" {
+		return user.Name
 	}
-	if inquiry.Owner.PublicPreference == "Anonymized" {
-		inquiry.Owner.Name = "Owner " + fmt.Sprint(inquiry.Owner.ID+200)
+	if user.ID == inquiry.SeekerID {
+		return "Requester " + fmt.Sprint(user.ID+500)
 	}
+	return "Owner " + fmt.Sprint(user.ID+200)
+}
 
-	for i := range inquiry.Messages {
-		if inquiry.Messages[i].SenderID == inquiry.SeekerID {
-			inquiry.Messages[i].Sender.Name = inquiry.Seeker.Name
-		} else {
-			inquiry.Messages[i].Sender.Name = inquiry.Owner.Name
-		}
+// threadDisplayName is the name user goes by in a chat thread. Threads that
+// carry an inquiry use the inquiry's aliases.
+func threadDisplayName(threadID uint, user models.User) string {
+	var inquiry models.Inquiry
+	if err := config.DB.Where("chat_thread_id = ?", threadID).First(&inquiry).Error; err != nil {
+		return user.Name
+	}
+	if user.ID != inquiry.SeekerID && user.ID != inquiry.OwnerID {
+		return user.Name
 	}
+	return inquiryDisplayName(inquiry, user)
+}
 
-	c.JSON(http.StatusOK, inquiry)
+// inquiryAliases holds the inquiries behind chat threads, keyed by thread ID,
+// so the seeker and owner can be shown under their inquiry names wherever the
+// thread is serialized.
+type inquiryAliases map[uint]models.Inquiry
+
+func loadInquiryAliases(threadIDs ...uint) inquiryAliases {
+	aliases := make(inquiryAliases)
+	if len(threadIDs) == 0 {
+		return aliases
+	}
+	var inquiries []models.Inquiry
+	config.DB.Where("chat_thread_id IN ?", threadIDs).Find(&inquiries)
+	for _, inquiry := range inquiries {
+		aliases[*inquiry.ChatThreadID] = inquiry
+	}
+	return aliases
 }
 
-func SendMessage(c *gin.Context) {
-	id := c.Param("id")
+// name is what user goes by in the thread's inquiry, if they are its seeker or owner.
+func (a inquiryAliases) name(threadID uint, user models.User) (string, bool) {
+	inquiry, ok := a[threadID]
+	if !ok || (user.ID != inquiry.SeekerID && user.ID != inquiry.OwnerID) {
+		return "", false
+	}
+	return inquiryDisplayName(inquiry, user), true
+}
+
+func (a inquiryAliases) user(threadID uint, user *models.User) {
+	if name, ok := a.name(threadID, *user); ok {
+		user.Name = name
+	}
+}
+
+func (a inquiryAliases) message(msg *models.ChatMessage) {
+	a.user(msg.ThreadID, &msg.Sender)
+	if msg.ReplyTo != nil {
+		a.message(msg.ReplyTo)
+	}
+}
+
+func (a inquiryAliases) messages(msgs []models.ChatMessage) {
+	for i := range msgs {
+		a.message(&msgs[i])
+	}
+}
+
+func (a inquiryAliases) thread(thread *models.ChatThread) {
+	for i := range thread.Participants {
+		a.user(thread.ID, &thread.Participants[i].User)
+	}
+	a.messages(thread.Messages)
+}
+
+// loadInquiryThread fetches an inquiry the caller takes part in together with
+// their membership of its chat thread. It writes the error response itself.
+func loadInquiryThread(c *gin.Context, userID uint) (models.Inquiry, models.ChatThread, models.ChatParticipant, bool) {
+	var inquiry models.Inquiry
+	if err := config.DB.First(&inquiry, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inquiry not found"})
+		return inquiry, models.ChatThread{}, models.ChatParticipant{}, false
+	}
+
+	if inquiry.SeekerID != userID && inquiry.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return inquiry, models.ChatThread{}, models.ChatParticipant{}, false
+	}
+
+	if inquiry.ChatThreadID == nil {
+		// Only possible before MigrateInquiryThreads has run
+		c.JSON(http.StatusConflict, gin.H{"error": "Inquiry conversation is not available yet"})
+		return inquiry, models.ChatThread{}, models.ChatParticipant{}, false
+	}
+
+	thread, participant, ok := loadThreadForParticipant(c, *inquiry.ChatThreadID, userID)
+	return inquiry, thread, participant, ok
+}
+
+// SendMessage posts a message to an inquiry's chat thread.
+func (cc *ChatController) SendMessage(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
 
 	var input struct {
@@ -141,22 +322,22 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
-	var inquiry models.Inquiry
-	if err := config.DB.First(&inquiry, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Inquiry not found"})
+	inquiry, thread, participant, ok := loadInquiryThread(c, userID)
+	if !ok {
 		return
 	}
 
-	// Check if participant
-	if inquiry.SeekerID != userID && inquiry.OwnerID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	if !canPostToThread(c, thread, participant) {
 		return
 	}
 
-	message := models.InquiryMessage{
-		InquiryID: inquiry.ID,
+	flagged := contactFilterFlags(input.Message, threadContactsConsented(thread.ID))
+
+	message := models.ChatMessage{
+		ThreadID:  thread.ID,
 		SenderID:  userID,
-		Message:   input.Message,
+		Content:   input.Message,
+		CreatedAt: time.Now(),
 	}
 
 	if err := config.DB.Create(&message).Error; err != nil {
@@ -167,23 +348,55 @@ func SendMessage(c *gin.Context) {
 	// Update inquiry timestamp
 	config.DB.Model(&inquiry).Update("updated_at", message.CreatedAt)
 
-	// Notify the other party
-	recipientID := inquiry.OwnerID
+	cc.publishChatMessage(thread, message, flagged)
+	cc.notifyChatRecipients(thread.ID, userID, input.Message)
+
+	newContactMasker().message(&message)
+	c.JSON(http.StatusCreated, message)
+}
+
+// ShareInquiryContact reveals the caller's phone number to the other party of
+// an inquiry and records their consent to share contact details.
+func (cc *ChatController) ShareInquiryContact(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	inquiry, thread, participant, ok := loadInquiryThread(c, userID)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.Phone == "" || !user.PhoneVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verify a phone number on your profile before sharing it"})
+		return
+	}
+
+	column := "seeker_shared_contact"
 	if userID == inquiry.OwnerID {
-		recipientID = inquiry.SeekerID
+		column = "owner_shared_contact"
 	}
+	config.DB.Model(&inquiry).Update(column, true)
 
-	notification := models.Notification{
-		UserID:  recipientID,
-		Content: "New message in inquiry thread",
-		Type:    "inquiry",
+	// Consent is tracked on the thread so chat and inquiry filtering agree
+	if participant.ContactSharedAt == nil {
+		config.DB.Model(&participant).Update("contact_shared_at", time.Now())
 	}
-	config.DB.Create(&notification)
 
-	c.JSON(http.StatusCreated, message)
+	// System messages bypass the contact filter
+	cc.postSystemMessage(thread.ID, userID, inquiryDisplayName(inquiry, user)+" shared their phone number: "+user.Phone)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "shared",
+		"all_shared": threadContactsConsented(thread.ID),
+	})
 }
 
-func UpdateInquiryStatus(c *gin.Context) {
+func (cc *ChatController) UpdateInquiryStatus(c *gin.Context) {
 	id := c.Param("id")
 	userID := c.MustGet("userID").(uint)
 
@@ -209,8 +422,14 @@ func UpdateInquiryStatus(c *gin.Context) {
 		return
 	}
 
+	changed := inquiry.Status != input.Status
 	inquiry.Status = input.Status
 	config.DB.Save(&inquiry)
 
+	if changed && inquiry.ChatThreadID != nil {
+		cc.postSystemMessage(*inquiry.ChatThreadID, userID, "Inquiry marked as "+inquiry.Status)
+	}
+
+	newContactMasker().inquiry(&inquiry)
 	c.JSON(http.StatusOK, inquiry)
 }
@@ -4,20 +4,85 @@ import (
 	"net/http"
 	"realstate-backend/config"
 	"realstate-backend/models"
+	"realstate-backend/notify"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// GetNotifications returns the caller's notifications newest first, paginated.
+// Filter with category (comma separated) and unread=true.
 func GetNotifications(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
 
+	page := 1
+	limit := 20
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	query := config.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category IN ?", strings.Split(category, ","))
+	}
+	if c.Query("unread") == "true" {
+		query = query.Where("is_read = ?", false)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	var notifications []models.Notification
-	if err := config.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&notifications).Error; err != nil {
+	if err := query.Order("created_at desc, id desc").Limit(limit).Offset((page - 1) * limit).Find(&notifications).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, notifications)
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unread_count":  notify.UnreadCount(userID),
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// GetUnreadNotificationCount returns the caller's unread total and a breakdown by category.
+func GetUnreadNotificationCount(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var rows []struct {
+		Category string
+		Count    int64
+	}
+	if err := config.DB.Model(&models.Notification{}).Select("category, COUNT(*) AS count").
+		Where("user_id = ? AND is_read = ?", userID, false).Group("category").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	var total int64
+	byCategory := make(map[string]int64)
+	for _, row := range rows {
+		byCategory[row.Category] = row.Count
+		total += row.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": total, "by_category": byCategory})
 }
 
 func MarkNotificationRead(c *gin.Context) {
@@ -28,17 +93,92 @@ func MarkNotificationRead(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
 		return
 	}
+	notify.PushUnreadCount(userID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
 }
 
+// MarkAllNotificationsRead marks the caller's notifications read, optionally only in some categories.
 func MarkAllNotificationsRead(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
 
-	if err := config.DB.Model(&models.Notification{}).Where("user_id = ?", userID).Update("is_read", true).Error; err != nil {
+	query := config.DB.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userID, false)
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category IN ?", strings.Split(category, ","))
+	}
+
+	if err := query.Update("is_read", true).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
 		return
 	}
+	notify.PushUnreadCount(userID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read"})
 }
+
+// GetNotificationDeliveries lists delivery attempts across channels (Admin only).
+// Filter with user_id, channel and status.
+func GetNotificationDeliveries(c *gin.Context) {
+	query := config.DB.Model(&models.NotificationDelivery{})
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if broadcastID := c.Query("broadcast_id"); broadcastID != "" {
+		query = query.Where("broadcast_id = ?", broadcastID)
+	}
+
+	var deliveries []models.NotificationDelivery
+	if err := query.Order("created_at desc").Limit(200).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func DeleteNotification(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.MustGet("userID").(uint)
+
+	result := config.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Notification{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notification"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	notify.PushUnreadCount(userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification deleted"})
+}
+
+// ClearNotifications deletes the caller's notifications. By default only read
+// ones are removed; pass all=true to clear everything, or category to limit it.
+func ClearNotifications(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	query := config.DB.Where("user_id = ?", userID)
+	if c.Query("all") != "true" {
+		query = query.Where("is_read = ?", true)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category IN ?", strings.Split(category, ","))
+	}
+
+	result := query.Delete(&models.Notification{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear notifications"})
+		return
+	}
+	notify.PushUnreadCount(userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications cleared", "deleted": result.RowsAffected})
+}
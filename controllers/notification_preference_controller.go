@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/notify"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// GetNotificationPreferences returns the caller's category-by-channel matrix
+// together with the options the client can offer for each channel.
+func GetNotificationPreferences(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	options := make(map[string][]string)
+	for _, channel := range notify.PreferenceChannels {
+		options[channel] = notify.ChannelFrequencies(channel)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"categories":  models.NotificationCategories,
+		"channels":    notify.PreferenceChannels,
+		"options":     options,
+		"preferences": notify.Preferences(userID),
+	})
+}
+
+// UpdateNotificationPreferences saves any cells of the matrix the client sends,
+// e.g. {"preferences": {"chat": {"email": "daily"}}}.
+func UpdateNotificationPreferences(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Preferences map[string]map[string]string `json:"preferences" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	validCategory := make(map[string]bool)
+	for _, category := range models.NotificationCategories {
+		validCategory[category] = true
+	}
+
+	var rows []models.NotificationPreference
+	for category, channels := range input.Preferences {
+		if !validCategory[category] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown category " + category})
+			return
+		}
+		for channel, frequency := range channels {
+			if !notify.ValidFrequency(channel, frequency) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid frequency " + frequency + " for " + channel})
+				return
+			}
+			rows = append(rows, models.NotificationPreference{
+				UserID:    userID,
+				Category:  category,
+				Channel:   channel,
+				Frequency: frequency,
+			})
+		}
+	}
+
+	if len(rows) > 0 {
+		err := config.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "category"}, {Name: "channel"}},
+			DoUpdates: clause.AssignmentColumns([]string{"frequency", "updated_at"}),
+		}).Create(&rows).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preferences"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preferences": notify.Preferences(userID)})
+}
+
+// RunNotificationDigests sends pending digests immediately (Admin only).
+func RunNotificationDigests(c *gin.Context) {
+	frequency := c.DefaultQuery("frequency", models.FrequencyDaily)
+	if frequency != models.FrequencyDaily && frequency != models.FrequencyWeekly {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "frequency must be daily or weekly"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"frequency": frequency, "sent": notify.RunDigests(frequency)})
+}
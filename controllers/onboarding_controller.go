@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/notify"
+	"realstate-backend/rbac"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxApplicationDocuments    = 5
+	maxApplicationDocumentSize = 10 << 20 // 10 MB
+)
+
+// Document types accepted with an application, keyed by sniffed MIME type.
+var applicationDocumentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+}
+
+var errApplicationReviewed = errors.New("application already reviewed")
+
+// Badge shown on a profile once an application for the role is approved.
+var applicationBadges = map[string]string{
+	models.ApplicationRoleDeveloper: "Verified Developer",
+	models.ApplicationRoleBroker:    "Verified Broker",
+}
+
+func applicationDocumentDir() string {
+	if dir := os.Getenv("APPLICATION_DOCUMENT_DIR"); dir != "" {
+		return dir
+	}
+	// Licences and IDs must not end up in ./uploads, which is served publicly
+	return "./application_documents"
+}
+
+// SubmitRoleApplication applies for the developer or broker role. It takes a
+// multipart form with role, company_name, license_number and one or more
+// documents.
+func SubmitRoleApplication(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	role := c.PostForm("role")
+	companyName := strings.TrimSpace(c.PostForm("company_name"))
+	licenseNumber := strings.TrimSpace(c.PostForm("license_number"))
+
+	if _, ok := applicationBadges[role]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be developer or broker"})
+		return
+	}
+	if companyName == "" || licenseNumber == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "company_name and license_number are required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Role == role {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have this role"})
+		return
+	}
+	// Approval replaces the account role, which would strip a staff member's access
+	if !rbac.IsAccountRole(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Staff accounts cannot apply for a member role"})
+		return
+	}
+
+	var pending int64
+	config.DB.Model(&models.RoleApplication{}).
+		Where("user_id = ? AND status = ?", userID, models.ApplicationStatusPending).Count(&pending)
+	if pending > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have an application under review"})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse form"})
+		return
+	}
+	files := form.File["documents"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one supporting document is required"})
+		return
+	}
+	if len(files) > maxApplicationDocuments {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d documents can be attached", maxApplicationDocuments)})
+		return
+	}
+
+	mimeTypes := make([]string, len(files))
+	for i, file := range files {
+		mimeType, err := sniffContentType(file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !applicationDocumentTypes[mimeType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: documents must be PDF, JPEG or PNG", filepath.Base(file.Filename))})
+			return
+		}
+		if file.Size > maxApplicationDocumentSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s exceeds the %d MB limit", filepath.Base(file.Filename), maxApplicationDocumentSize>>20)})
+			return
+		}
+		mimeTypes[i] = mimeType
+	}
+
+	dir := filepath.Join(applicationDocumentDir(), strconv.Itoa(int(userID)))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store documents"})
+		return
+	}
+
+	application := models.RoleApplication{
+		UserID:        userID,
+		Role:          role,
+		CompanyName:   companyName,
+		LicenseNumber: licenseNumber,
+		Status:        models.ApplicationStatusPending,
+	}
+	var stored []string
+	for i, file := range files {
+		// Never trust the client filename for the on-disk path
+		path := filepath.Join(dir, fmt.Sprintf("%d-%d%s", time.Now().UnixNano(), i, strings.ToLower(filepath.Ext(file.Filename))))
+		if err := c.SaveUploadedFile(file, path); err != nil {
+			removeFiles(stored)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store documents"})
+			return
+		}
+		stored = append(stored, path)
+		application.Documents = append(application.Documents, models.ApplicationDocument{
+			FileName:    filepath.Base(file.Filename),
+			MimeType:    mimeTypes[i],
+			Size:        file.Size,
+			StoragePath: path,
+		})
+	}
+
+	if err := config.DB.Create(&application).Error; err != nil {
+		removeFiles(stored)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit application"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, application)
+}
+
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+// GetMyRoleApplications lists the caller's applications, newest first
+func GetMyRoleApplications(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var applications []models.RoleApplication
+	config.DB.Preload("Documents").Where("user_id = ?", userID).Order("created_at DESC").Find(&applications)
+	c.JSON(http.StatusOK, applications)
+}
+
+// WithdrawRoleApplication cancels the caller's application while it is still pending
+func WithdrawRoleApplication(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	result := config.DB.Model(&models.RoleApplication{}).
+		Where("id = ? AND user_id = ? AND status = ?", c.Param("id"), userID, models.ApplicationStatusPending).
+		Update("status", models.ApplicationStatusWithdrawn)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to withdraw application"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No pending application found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Application withdrawn"})
+}
+
+// DownloadApplicationDocument serves a supporting document to the applicant
+// or to staff who review applications.
+func DownloadApplicationDocument(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var application models.RoleApplication
+	if err := config.DB.First(&application, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		return
+	}
+	if application.UserID != userID && !rbac.Can(userID, rbac.PermApplicationsReview) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var document models.ApplicationDocument
+	if err := config.DB.Where("id = ? AND application_id = ?", c.Param("docId"), application.ID).First(&document).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	c.Header("Content-Type", document.MimeType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.FileAttachment(document.StoragePath, document.FileName)
+}
+
+// GetRoleApplications lists applications for review, pending ones by default
+func GetRoleApplications(c *gin.Context) {
+	status := c.DefaultQuery("status", models.ApplicationStatusPending)
+
+	query := config.DB.Preload("User").Preload("Documents").Order("created_at ASC")
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	var applications []models.RoleApplication
+	if err := query.Find(&applications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applications"})
+		return
+	}
+	c.JSON(http.StatusOK, applications)
+}
+
+// loadPendingApplication fetches the application in the route and makes sure
+// it can still be reviewed. It writes the error response itself.
+func loadPendingApplication(c *gin.Context) (models.RoleApplication, bool) {
+	var application models.RoleApplication
+	if err := config.DB.Preload("User").First(&application, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		return application, false
+	}
+	if application.Status != models.ApplicationStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Application has already been " + application.Status})
+		return application, false
+	}
+	return application, true
+}
+
+// ApproveRoleApplication gives the applicant the role, its verified badge and
+// the company name from the application.
+func ApproveRoleApplication(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Note string `json:"note"`
+	}
+	c.ShouldBindJSON(&input)
+
+	application, ok := loadPendingApplication(c)
+	if !ok {
+		return
+	}
+	if application.User == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The applicant's account no longer exists"})
+		return
+	}
+	if !rbac.IsAccountRole(application.User.Role) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The applicant now has a staff role; change it before approving"})
+		return
+	}
+
+	now := time.Now()
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RoleApplication{}).
+			Where("id = ? AND status = ?", application.ID, models.ApplicationStatusPending).
+			Updates(map[string]interface{}{
+				"status":         models.ApplicationStatusApproved,
+				"review_note":    input.Note,
+				"reviewed_by_id": adminID,
+				"reviewed_at":    now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errApplicationReviewed
+		}
+		return tx.Model(&models.User{}).Where("id = ?", application.UserID).Updates(map[string]interface{}{
+			"role":         application.Role,
+			"badge":        applicationBadges[application.Role],
+			"company_name": application.CompanyName,
+		}).Error
+	})
+	if err == errApplicationReviewed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Application has already been reviewed"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve application"})
+		return
+	}
+	rbac.Invalidate(application.UserID)
+
+	recordAudit(adminID, "approve_application", "user", application.UserID,
+		fmt.Sprintf("application %d: %s (%s, licence %s)", application.ID, application.Role, application.CompanyName, application.LicenseNumber))
+
+	notify.Send(models.Notification{
+		UserID:    application.UserID,
+		Content:   fmt.Sprintf("Your application to join as a %s has been approved. Your profile now shows the %s badge.", application.Role, applicationBadges[application.Role]),
+		Category:  models.NotificationCategoryAccount,
+		Severity:  models.SeveritySuccess,
+		ActionURL: "/profile",
+		Data:      models.JSONMap{"application_id": application.ID, "role": application.Role},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Application approved"})
+}
+
+// RejectRoleApplication declines an application with a reason the applicant sees
+func RejectRoleApplication(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	application, ok := loadPendingApplication(c)
+	if !ok {
+		return
+	}
+
+	result := config.DB.Model(&models.RoleApplication{}).
+		Where("id = ? AND status = ?", application.ID, models.ApplicationStatusPending).
+		Updates(map[string]interface{}{
+			"status":         models.ApplicationStatusRejected,
+			"review_note":    input.Reason,
+			"reviewed_by_id": adminID,
+			"reviewed_at":    time.Now(),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject application"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Application has already been reviewed"})
+		return
+	}
+
+	recordAudit(adminID, "reject_application", "user", application.UserID,
+		fmt.Sprintf("application %d: %s", application.ID, input.Reason))
+
+	notify.Send(models.Notification{
+		UserID:    application.UserID,
+		Content:   fmt.Sprintf("Your application to join as a %s was not approved: %s", application.Role, input.Reason),
+		Category:  models.NotificationCategoryAccount,
+		Severity:  models.SeverityWarning,
+		ActionURL: "/profile",
+		Data:      models.JSONMap{"application_id": application.ID, "role": application.Role},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Application rejected"})
+}
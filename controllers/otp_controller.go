@@ -0,0 +1,320 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"realstate-backend/config"
+	"realstate-backend/middleware"
+	"realstate-backend/models"
+	"realstate-backend/notify"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	otpLength      = 6
+	otpTTL         = 5 * time.Minute
+	otpMaxAttempts = 5
+	otpCooldown    = time.Minute
+	otpPerHour     = 5 // Codes texted to one number per hour
+)
+
+// Per-number and per-account limits, on top of the per-IP route limits. The
+// send limits in sendOTP are per number; these also cap guessing across codes.
+var (
+	otpLoginLimiter  = middleware.NewLimiter(10, time.Hour) // Sign-in attempts per phone number
+	otpVerifyLimiter = middleware.NewLimiter(10, time.Hour) // Verification sends and attempts per user
+)
+
+var (
+	errOTPCooldown    = errors.New("please wait before requesting another code")
+	errOTPRateLimited = errors.New("too many codes requested for this number, try again later")
+	errOTPInvalid     = errors.New("invalid or expired code")
+	errOTPAttempts    = errors.New("too many wrong attempts, request a new code")
+)
+
+// hashOTP keys the code to its number and the server secret, so a leaked table
+// cannot be brute-forced offline.
+func hashOTP(phone, code string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte(phone + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendOTP texts a fresh code to phone, replacing any earlier unused one
+func sendOTP(phone, purpose string, userID *uint) error {
+	now := time.Now()
+
+	var recent []models.PhoneOTP
+	config.DB.Where("phone = ? AND created_at > ?", phone, now.Add(-time.Hour)).Order("created_at desc").Find(&recent)
+	if len(recent) > 0 && now.Sub(recent[0].CreatedAt) < otpCooldown {
+		return errOTPCooldown
+	}
+	if len(recent) >= otpPerHour {
+		return errOTPRateLimited
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("%0*d", otpLength, n.Int64())
+
+	// Only the newest code for a number and purpose is valid
+	config.DB.Model(&models.PhoneOTP{}).
+		Where("phone = ? AND purpose = ? AND consumed_at IS NULL", phone, purpose).
+		Update("consumed_at", now)
+
+	otp := models.PhoneOTP{
+		Phone:     phone,
+		UserID:    userID,
+		Purpose:   purpose,
+		CodeHash:  hashOTP(phone, code),
+		ExpiresAt: now.Add(otpTTL),
+	}
+	if err := config.DB.Create(&otp).Error; err != nil {
+		return err
+	}
+
+	return notify.SendAccountSMS(phone, fmt.Sprintf("%s is your RJG Property Connect code. It expires in %d minutes. Do not share it with anyone.", code, int(otpTTL.Minutes())))
+}
+
+// checkOTP consumes the pending code for phone if it matches. Each wrong guess
+// counts against the code, which stops working after otpMaxAttempts.
+func checkOTP(phone, purpose, code string, userID *uint) error {
+	query := config.DB.Where("phone = ? AND purpose = ? AND consumed_at IS NULL", phone, purpose)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	var otp models.PhoneOTP
+	if err := query.Order("created_at desc").First(&otp).Error; err != nil {
+		return errOTPInvalid
+	}
+	if time.Now().After(otp.ExpiresAt) {
+		return errOTPInvalid
+	}
+	if otp.Attempts >= otpMaxAttempts {
+		return errOTPAttempts
+	}
+
+	// Count the attempt before comparing, atomically, so parallel guesses
+	// cannot all slip in under the cap
+	result := config.DB.Model(&models.PhoneOTP{}).
+		Where("id = ? AND attempts < ?", otp.ID, otpMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errOTPAttempts
+	}
+
+	if !hmac.Equal([]byte(hashOTP(phone, code)), []byte(otp.CodeHash)) {
+		if otp.Attempts+1 >= otpMaxAttempts {
+			return errOTPAttempts
+		}
+		return errOTPInvalid
+	}
+
+	// Conditional so the same code cannot be used twice concurrently
+	result = config.DB.Model(&models.PhoneOTP{}).Where("id = ? AND consumed_at IS NULL", otp.ID).Update("consumed_at", time.Now())
+	if result.RowsAffected == 0 {
+		return errOTPInvalid
+	}
+	return nil
+}
+
+func otpErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errOTPCooldown), errors.Is(err, errOTPRateLimited), errors.Is(err, errOTPAttempts):
+		return http.StatusTooManyRequests
+	case errors.Is(err, errOTPInvalid):
+		return http.StatusUnauthorized
+	case errors.Is(err, notify.ErrSMSNotConfigured):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// SendLoginOTP texts a sign-in code to a verified phone number. The reply is
+// the same for unknown numbers so it cannot be used to find accounts; that
+// includes the per-number cooldown, so sends it refuses are dropped silently.
+func SendLoginOTP(c *gin.Context) {
+	var input struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, err := models.NormalizePhone(input.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		return
+	}
+
+	// Checked up front so the reply does not depend on whether the number is registered
+	if !notify.SMSConfigured() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Sign-in codes by text are not available right now"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Unscoped().Where("phone = ? AND phone_verified = ?", phone, true).First(&user).Error; err == nil {
+		if err := sendOTP(phone, models.OTPPurposeLogin, &user.ID); err != nil {
+			log.Printf("Login code not sent to user %d: %v", user.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If this number is registered, a code has been sent", "expires_in": int(otpTTL.Seconds())})
+}
+
+// LoginWithOTP signs in with a phone number and the code texted to it
+func LoginWithOTP(c *gin.Context) {
+	var input struct {
+		Phone string `json:"phone" binding:"required"`
+		Code  string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, err := models.NormalizePhone(input.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		return
+	}
+
+	if ok, retryAfter := otpLoginLimiter.Allow(phone); !ok {
+		middleware.TooManyRequests(c, retryAfter)
+		return
+	}
+
+	if err := checkOTP(phone, models.OTPPurposeLogin, input.Code, nil); err != nil {
+		c.JSON(otpErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	// Use Unscoped to find deactivated users too, like password login
+	if err := config.DB.Unscoped().Where("phone = ? AND phone_verified = ?", phone, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errOTPInvalid.Error()})
+		return
+	}
+	if !reactivateAccount(c, &user) {
+		return
+	}
+
+	finishLogin(c, user, gin.H{
+		"id":    user.ID,
+		"name":  user.Name,
+		"role":  user.Role,
+		"email": user.Email,
+		"phone": user.Phone,
+	})
+}
+
+// SendPhoneVerificationOTP texts a code to the number the caller wants on their account
+func SendPhoneVerificationOTP(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, err := models.NormalizePhone(input.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		return
+	}
+
+	if !notify.SMSConfigured() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Verification codes by text are not available right now"})
+		return
+	}
+
+	if ok, retryAfter := otpVerifyLimiter.Allow(fmt.Sprint("send:", userID)); !ok {
+		middleware.TooManyRequests(c, retryAfter)
+		return
+	}
+
+	var taken int64
+	config.DB.Model(&models.User{}).Where("phone = ? AND phone_verified = ? AND id <> ?", phone, true, userID).Count(&taken)
+	if taken > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This number is already verified on another account"})
+		return
+	}
+
+	if err := sendOTP(phone, models.OTPPurposeVerifyPhone, &userID); err != nil {
+		log.Printf("Failed to send verification code to user %d: %v", userID, err)
+		c.JSON(otpErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Code sent", "phone": phone, "expires_in": int(otpTTL.Seconds())})
+}
+
+// VerifyPhone sets the caller's phone to a number they proved they own
+func VerifyPhone(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Phone string `json:"phone" binding:"required"`
+		Code  string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, err := models.NormalizePhone(input.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		return
+	}
+
+	if ok, retryAfter := otpVerifyLimiter.Allow(fmt.Sprint("check:", userID)); !ok {
+		middleware.TooManyRequests(c, retryAfter)
+		return
+	}
+
+	if err := checkOTP(phone, models.OTPPurposeVerifyPhone, input.Code, &userID); err != nil {
+		c.JSON(otpErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// The unique index on verified numbers settles a race with another account
+	if err := config.DB.Model(&user).Updates(map[string]interface{}{
+		"phone":             phone,
+		"phone_verified":    true,
+		"phone_verified_at": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This number is already verified on another account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
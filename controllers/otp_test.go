@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/notify"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createTestOTP stores a pending code for a number unique to the test.
+func createTestOTP(t *testing.T, code string, expiresAt time.Time) (string, models.PhoneOTP) {
+	t.Helper()
+	phone := fmt.Sprintf("+9199%08d", time.Now().UnixNano()%100_000_000)
+	otp := models.PhoneOTP{
+		Phone:     phone,
+		Purpose:   models.OTPPurposeLogin,
+		CodeHash:  hashOTP(phone, code),
+		ExpiresAt: expiresAt,
+	}
+	if err := config.DB.Create(&otp).Error; err != nil {
+		t.Fatalf("create otp: %v", err)
+	}
+	t.Cleanup(func() { config.DB.Where("phone = ?", phone).Delete(&models.PhoneOTP{}) })
+	return phone, otp
+}
+
+func TestCheckOTP(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	setupTestDB(t, &models.PhoneOTP{})
+
+	phone, _ := createTestOTP(t, "123456", time.Now().Add(otpTTL))
+
+	if err := checkOTP(phone, models.OTPPurposeVerifyPhone, "123456", nil); !errors.Is(err, errOTPInvalid) {
+		t.Errorf("code accepted for another purpose: %v", err)
+	}
+	if err := checkOTP(phone, models.OTPPurposeLogin, "123456", nil); err != nil {
+		t.Fatalf("correct code refused: %v", err)
+	}
+	if err := checkOTP(phone, models.OTPPurposeLogin, "123456", nil); !errors.Is(err, errOTPInvalid) {
+		t.Errorf("code accepted twice: %v", err)
+	}
+}
+
+func TestCheckOTPExpired(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	setupTestDB(t, &models.PhoneOTP{})
+
+	phone, _ := createTestOTP(t, "123456", time.Now().Add(-time.Second))
+
+	if err := checkOTP(phone, models.OTPPurposeLogin, "123456", nil); !errors.Is(err, errOTPInvalid) {
+		t.Errorf("expired code: got %v, want %v", err, errOTPInvalid)
+	}
+}
+
+func TestCheckOTPAttemptLimit(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	setupTestDB(t, &models.PhoneOTP{})
+
+	phone, _ := createTestOTP(t, "123456", time.Now().Add(otpTTL))
+
+	for i := 1; i < otpMaxAttempts; i++ {
+		if err := checkOTP(phone, models.OTPPurposeLogin, "000000", nil); !errors.Is(err, errOTPInvalid) {
+			t.Fatalf("wrong guess %d: got %v, want %v", i, err, errOTPInvalid)
+		}
+	}
+	if err := checkOTP(phone, models.OTPPurposeLogin, "000000", nil); !errors.Is(err, errOTPAttempts) {
+		t.Errorf("last wrong guess: got %v, want %v", err, errOTPAttempts)
+	}
+	if err := checkOTP(phone, models.OTPPurposeLogin, "123456", nil); !errors.Is(err, errOTPAttempts) {
+		t.Errorf("correct code after the limit: got %v, want %v", err, errOTPAttempts)
+	}
+}
+
+func TestCheckOTPConcurrentGuesses(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	setupTestDB(t, &models.PhoneOTP{})
+
+	phone, otp := createTestOTP(t, "123456", time.Now().Add(otpTTL))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4*otpMaxAttempts; i++ {
+		wg.Add(1)
+		go func(guess int) {
+			defer wg.Done()
+			checkOTP(phone, models.OTPPurposeLogin, fmt.Sprintf("%06d", guess), nil)
+		}(i)
+	}
+	wg.Wait()
+
+	var attempts int
+	config.DB.Model(&models.PhoneOTP{}).Where("id = ?", otp.ID).Pluck("attempts", &attempts)
+	if attempts != otpMaxAttempts {
+		t.Errorf("%d attempts counted, want exactly %d", attempts, otpMaxAttempts)
+	}
+}
+
+func TestOTPErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errOTPInvalid, http.StatusUnauthorized},
+		{errOTPAttempts, http.StatusTooManyRequests},
+		{errOTPCooldown, http.StatusTooManyRequests},
+		{errOTPRateLimited, http.StatusTooManyRequests},
+		{notify.ErrSMSNotConfigured, http.StatusServiceUnavailable},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := otpErrorStatus(tt.err); got != tt.want {
+			t.Errorf("otpErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestHashOTPIsKeyedToNumber(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	if hashOTP("+919425212345", "123456") == hashOTP("+919425212346", "123456") {
+		t.Error("the same code hashed alike for two numbers")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"realstate-backend/config"
 	"realstate-backend/models"
+	"realstate-backend/notify"
+	"realstate-backend/rbac"
 
 	"github.com/gin-gonic/gin"
 )
@@ -70,13 +72,14 @@ func CreateProperty(c *gin.Context) {
 	}
 
 	// Auto-Reply Notification
-	notification := models.Notification{
-		UserID:  property.OwnerID,
-		Content: "Your property '" + property.Title + "' has been successfully listed! It is now visible to all users.",
-		Type:    "system",
-		IsRead:  false,
-	}
-	config.DB.Create(&notification)
+	notify.Send(models.Notification{
+		UserID:     property.OwnerID,
+		Content:    "Your property '" + property.Title + "' has been successfully listed! It is now visible to all users.",
+		Category:   models.NotificationCategoryListing,
+		Severity:   models.SeveritySuccess,
+		EntityType: "property",
+		EntityID:   &property.ID,
+	})
 
 	c.JSON(http.StatusCreated, property)
 }
@@ -84,7 +87,7 @@ func CreateProperty(c *gin.Context) {
 func UpdateProperty(c *gin.Context) {
 	id := c.Param("id")
 	userID := c.GetUint("userID")
-	userRole := c.GetString("role")
+	canManage := rbac.Can(userID, rbac.PermListingsManage)
 
 	var property models.Property
 	if err := config.DB.First(&property, id).Error; err != nil {
@@ -93,7 +96,7 @@ func UpdateProperty(c *gin.Context) {
 	}
 
 	// Check ownership or admin
-	if property.OwnerID != userID && userRole != "admin" {
+	if property.OwnerID != userID && !canManage {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this property"})
 		return
 	}
@@ -121,7 +124,7 @@ func UpdateProperty(c *gin.Context) {
 func DeleteProperty(c *gin.Context) {
 	id := c.Param("id")
 	userID := c.GetUint("userID")
-	userRole := c.GetString("role")
+	canManage := rbac.Can(userID, rbac.PermListingsManage)
 
 	var property models.Property
 	if err := config.DB.First(&property, id).Error; err != nil {
@@ -129,7 +132,7 @@ func DeleteProperty(c *gin.Context) {
 		return
 	}
 
-	if property.OwnerID != userID && userRole != "admin" {
+	if property.OwnerID != userID && !canManage {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to delete this property"})
 		return
 	}
@@ -140,15 +143,14 @@ func DeleteProperty(c *gin.Context) {
 	}
 
 	// Notify if Admin deleted it (Reject) and it wasn't the owner
-	if userRole == "admin" && property.OwnerID != userID {
-		content := "Your property '" + property.Title + "' has been removed by the administrator."
-		notification := models.Notification{
-			UserID:  property.OwnerID,
-			Content: content,
-			Type:    "system",
-			IsRead:  false,
-		}
-		config.DB.Create(&notification)
+	if canManage && property.OwnerID != userID {
+		notify.Send(models.Notification{
+			UserID:   property.OwnerID,
+			Content:  "Your property '" + property.Title + "' has been removed by the administrator.",
+			Category: models.NotificationCategoryListing,
+			Severity: models.SeverityWarning,
+			Data:     models.JSONMap{"property_id": property.ID, "title": property.Title},
+		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Property deleted successfully"})
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/notify"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// GetVAPIDPublicKey returns the application server key the browser needs to subscribe
+func GetVAPIDPublicKey(c *gin.Context) {
+	key := notify.WebPushPublicKey()
+	if key == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Web push is not configured"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"public_key": key})
+}
+
+// GetPushSubscriptions lists the caller's registered devices
+func GetPushSubscriptions(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var subscriptions []models.PushSubscription
+	if err := config.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// SubscribePush registers a browser's PushSubscription. The body is the
+// subscription's toJSON() output. Re-subscribing the same endpoint refreshes
+// its keys.
+func SubscribePush(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Endpoint       string `json:"endpoint" binding:"required"`
+		ExpirationTime *int64 `json:"expirationTime"` // Milliseconds since epoch
+		Keys           struct {
+			P256dh string `json:"p256dh" binding:"required"`
+			Auth   string `json:"auth" binding:"required"`
+		} `json:"keys" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := notify.ValidatePushEndpoint(input.Endpoint); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := notify.ValidatePushKeys(input.Keys.P256dh, input.Keys.Auth); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription := models.PushSubscription{
+		UserID:    userID,
+		Endpoint:  input.Endpoint,
+		P256dh:    input.Keys.P256dh,
+		Auth:      input.Keys.Auth,
+		UserAgent: c.Request.UserAgent(),
+	}
+	if input.ExpirationTime != nil {
+		expires := time.UnixMilli(*input.ExpirationTime)
+		subscription.ExpiresAt = &expires
+	}
+
+	// Only the caller's own rows are refreshed; an endpoint registered to
+	// someone else is left alone. It must have been unsubscribed first.
+	result := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"p256dh", "auth", "user_agent", "expires_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: "push_subscriptions", Name: "user_id"}, Value: userID}}},
+	}).Create(&subscription)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save subscription"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This endpoint is registered to another account"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// UnsubscribePush removes one of the caller's subscriptions, by endpoint in the
+// body (what the browser knows after unsubscribe()) or by id in the path.
+func UnsubscribePush(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	query := config.DB.Where("user_id = ?", userID)
+	if id := c.Param("id"); id != "" {
+		query = query.Where("id = ?", id)
+	} else {
+		var input struct {
+			Endpoint string `json:"endpoint" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query = query.Where("endpoint = ?", input.Endpoint)
+	}
+
+	result := query.Delete(&models.PushSubscription{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove subscription"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed"})
+}
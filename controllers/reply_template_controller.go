@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// systemReplyTemplates returns the defaults with any admin edits from site config applied.
+func systemReplyTemplates() []models.SystemReplyTemplate {
+	keys := make([]string, len(models.DefaultReplyTemplates))
+	for i, t := range models.DefaultReplyTemplates {
+		keys[i] = t.Key
+	}
+
+	var configs []models.SiteConfig
+	config.DB.Where("key IN ?", keys).Find(&configs)
+	overrides := make(map[string]string)
+	for _, cfg := range configs {
+		if strings.TrimSpace(cfg.Value) != "" {
+			overrides[cfg.Key] = cfg.Value
+		}
+	}
+
+	templates := make([]models.SystemReplyTemplate, len(models.DefaultReplyTemplates))
+	for i, t := range models.DefaultReplyTemplates {
+		if body, ok := overrides[t.Key]; ok {
+			t.Body = body
+		}
+		templates[i] = t
+	}
+	return templates
+}
+
+// GetReplyTemplates returns the system defaults and the caller's saved templates
+func GetReplyTemplates(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var templates []models.ReplyTemplate
+	if err := config.DB.Where("user_id = ?", userID).Order("title ASC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"system":       systemReplyTemplates(),
+		"templates":    templates,
+		"placeholders": []string{"{property_title}", "{price}", "{location}", "{my_name}", "{my_phone}"},
+	})
+}
+
+func CreateReplyTemplate(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Title string `json:"title" binding:"required"`
+		Body  string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := models.ReplyTemplate{UserID: userID, Title: input.Title, Body: input.Body}
+	if err := config.DB.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+func UpdateReplyTemplate(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var template models.ReplyTemplate
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	var input struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Title != "" {
+		template.Title = input.Title
+	}
+	if input.Body != "" {
+		template.Body = input.Body
+	}
+	if err := config.DB.Save(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func DeleteReplyTemplate(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	result := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&models.ReplyTemplate{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+}
+
+// RenderReplyTemplate fills a template's placeholders for a chat thread or
+// inquiry the caller takes part in. The result is returned for the client to
+// review and send through the normal message endpoints, so blocking and
+// contact filtering still apply.
+func RenderReplyTemplate(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		TemplateID uint   `json:"template_id"`
+		SystemKey  string `json:"system_key"`
+		ThreadID   uint   `json:"thread_id"`
+		InquiryID  uint   `json:"inquiry_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (input.TemplateID == 0) == (input.SystemKey == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either template_id or system_key"})
+		return
+	}
+	if (input.ThreadID == 0) == (input.InquiryID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either thread_id or inquiry_id"})
+		return
+	}
+
+	var body string
+	if input.TemplateID != 0 {
+		var template models.ReplyTemplate
+		if err := config.DB.Where("id = ? AND user_id = ?", input.TemplateID, userID).First(&template).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
+		body = template.Body
+	} else {
+		for _, t := range systemReplyTemplates() {
+			if t.Key == input.SystemKey {
+				body = t.Body
+			}
+		}
+		if body == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
+	}
+
+	var property *models.Property
+	if input.ThreadID != 0 {
+		thread, _, ok := loadThreadForParticipant(c, input.ThreadID, userID)
+		if !ok {
+			return
+		}
+		if thread.PropertyID != nil {
+			var p models.Property
+			if err := config.DB.First(&p, *thread.PropertyID).Error; err == nil {
+				property = &p
+			}
+		}
+	} else {
+		var inquiry models.Inquiry
+		if err := config.DB.Preload("Property").First(&inquiry, input.InquiryID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Inquiry not found"})
+			return
+		}
+		if inquiry.SeekerID != userID && inquiry.OwnerID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		property = &inquiry.Property
+	}
+
+	var user models.User
+	config.DB.First(&user, userID)
+
+	c.JSON(http.StatusOK, gin.H{"content": fillReplyTemplate(body, property, user)})
+}
+
+// fillReplyTemplate substitutes the supported placeholders. Property fields are
+// left blank when the conversation is not about a property.
+func fillReplyTemplate(body string, property *models.Property, user models.User) string {
+	title, price, location := "", "", ""
+	if property != nil {
+		title = property.Title
+		price = fmt.Sprintf("₹%.0f", property.Price)
+		location = property.Location
+	}
+
+	return strings.NewReplacer(
+		"{property_title}", title,
+		"{price}", price,
+		"{location}", location,
+		"{my_name}", user.Name,
+		"{my_phone}", user.Phone,
+	).Replace(body)
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/notify"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var validReportTargets = map[string]bool{"user": true, "message": true, "property": true, "requirement": true}
+
+var validReportReasons = map[string]bool{"spam": true, "harassment": true, "fraud": true, "inappropriate": true, "other": true}
+
+var errNoTakedown = errors.New("This kind of report has no content to remove")
+
+// CreateReport flags a user, chat message, property or requirement for moderation
+func CreateReport(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	var input struct {
+		TargetType string `json:"target_type" binding:"required"`
+		TargetID   uint   `json:"target_id" binding:"required"`
+		Reason     string `json:"reason" binding:"required"`
+		Details    string `json:"details"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !validReportTargets[input.TargetType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target_type must be user, message, property or requirement"})
+		return
+	}
+	if !validReportReasons[input.Reason] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason must be spam, harassment, fraud, inappropriate or other"})
+		return
+	}
+
+	// Make sure the target exists and, for messages, that the reporter could see it
+	var err error
+	switch input.TargetType {
+	case "user":
+		err = config.DB.First(&models.User{}, input.TargetID).Error
+	case "property":
+		err = config.DB.First(&models.Property{}, input.TargetID).Error
+	case "requirement":
+		err = config.DB.First(&models.Requirement{}, input.TargetID).Error
+	case "message":
+		var message models.ChatMessage
+		if err = config.DB.First(&message, input.TargetID).Error; err == nil {
+			var participants int64
+			config.DB.Model(&models.ChatParticipant{}).
+				Where("thread_id = ? AND user_id = ?", message.ThreadID, userID).Count(&participants)
+			if participants == 0 {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reported item not found"})
+		return
+	}
+
+	var existing int64
+	config.DB.Model(&models.Report{}).
+		Where("reporter_id = ? AND target_type = ? AND target_id = ? AND status = ?",
+			userID, input.TargetType, input.TargetID, models.ReportStatusPending).
+		Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this"})
+		return
+	}
+
+	report := models.Report{
+		ReporterID: &userID,
+		TargetType: input.TargetType,
+		TargetID:   input.TargetID,
+		Reason:     input.Reason,
+		Details:    input.Details,
+		Status:     models.ReportStatusPending,
+	}
+	if err := config.DB.Create(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// GetReports lists the moderation queue, pending reports first
+func GetReports(c *gin.Context) {
+	query := config.DB.Preload("Reporter")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	var reports []models.Report
+	if err := query.Order("CASE WHEN status = 'pending' THEN 0 ELSE 1 END, created_at desc").Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// ResolveReport closes a report as valid, optionally taking down the reported item
+func ResolveReport(c *gin.Context) {
+	closeReport(c, models.ReportStatusResolved)
+}
+
+// DismissReport closes a report without action
+func DismissReport(c *gin.Context) {
+	closeReport(c, models.ReportStatusDismissed)
+}
+
+func closeReport(c *gin.Context, status string) {
+	adminID := c.MustGet("userID").(uint)
+	var input struct {
+		Note          string `json:"note"`
+		RemoveContent bool   `json:"remove_content"` // Only honoured when resolving
+	}
+	// Body is optional
+	c.ShouldBindJSON(&input)
+
+	var report models.Report
+	if err := config.DB.First(&report, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	if report.Status != models.ReportStatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Report has already been closed"})
+		return
+	}
+
+	if status == models.ReportStatusResolved && input.RemoveContent {
+		// Suspending a reported staff member needs the same rights as banning them
+		if report.TargetType == "user" {
+			var target models.User
+			if err := config.DB.Unscoped().First(&target, report.TargetID).Error; err == nil && !canActOnAccount(c, adminID, target) {
+				return
+			}
+		}
+		if err := takeDownReportedItem(report); err == errNoTakedown {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove reported content"})
+			return
+		}
+	}
+
+	now := time.Now()
+	report.Status = status
+	report.ResolvedByID = &adminID
+	report.ResolutionNote = input.Note
+	report.ResolvedAt = &now
+	if err := config.DB.Save(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+
+	// System reports have nobody to notify
+	if report.ReporterID == nil {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	outcome := "reviewed and closed"
+	if status == models.ReportStatusResolved {
+		outcome = "reviewed and action has been taken"
+	}
+	notify.Send(models.Notification{
+		UserID:   *report.ReporterID,
+		Content:  "Your report has been " + outcome + ". Thank you for helping keep the community safe.",
+		Category: models.NotificationCategoryModeration,
+		Data:     models.JSONMap{"report_id": report.ID, "status": report.Status},
+	})
+
+	c.JSON(http.StatusOK, report)
+}
+
+// takeDownReportedItem hides the reported content: listings are deactivated,
+// messages are deleted, inquiries are closed with contact details removed from
+// their opening message and users are suspended.
+func takeDownReportedItem(report models.Report) error {
+	switch report.TargetType {
+	case "property":
+		return config.DB.Model(&models.Property{}).Where("id = ?", report.TargetID).Update("is_active", false).Error
+	case "requirement":
+		return config.DB.Model(&models.Requirement{}).Where("id = ?", report.TargetID).Update("is_active", false).Error
+	case "message":
+		return config.DB.Model(&models.ChatMessage{}).Where("id = ?", report.TargetID).
+			Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now()}).Error
+	case "inquiry":
+		var inquiry models.Inquiry
+		if err := config.DB.First(&inquiry, report.TargetID).Error; err != nil {
+			return err
+		}
+		masked, _ := maskContactDetails(inquiry.InitialMessage)
+		return config.DB.Model(&inquiry).Updates(map[string]interface{}{"initial_message": masked, "status": "Closed"}).Error
+	case "inquiry_message":
+		return config.DB.Delete(&models.InquiryMessage{}, report.TargetID).Error
+	case "user":
+		return suspendUser(report.TargetID)
+	}
+	return errNoTakedown
+}
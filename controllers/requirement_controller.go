@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"realstate-backend/config"
 	"realstate-backend/models"
+	"realstate-backend/notify"
+	"realstate-backend/rbac"
 
 	"github.com/gin-gonic/gin"
 )
@@ -62,13 +64,14 @@ func CreateRequirement(c *gin.Context) {
 	}
 
 	// Auto-Reply Notification
-	notification := models.Notification{
-		UserID:  requirement.UserID,
-		Content: "Your requirement for '" + requirement.Type + "' has been successfully posted! Property owners will contact you soon.",
-		Type:    "system",
-		IsRead:  false,
-	}
-	config.DB.Create(&notification)
+	notify.Send(models.Notification{
+		UserID:     requirement.UserID,
+		Content:    "Your requirement for '" + requirement.Type + "' has been successfully posted! Property owners will contact you soon.",
+		Category:   models.NotificationCategoryRequirement,
+		Severity:   models.SeveritySuccess,
+		EntityType: "requirement",
+		EntityID:   &requirement.ID,
+	})
 
 	c.JSON(http.StatusCreated, requirement)
 }
@@ -83,10 +86,10 @@ func UpdateRequirement(c *gin.Context) {
 
 	// Authorization
 	userID, _ := c.Get("userID")
-	userRole := c.GetString("role")
 	uid, _ := userID.(uint)
+	canManage := rbac.Can(uid, rbac.PermListingsManage)
 
-	if requirement.UserID != uid && userRole != "admin" {
+	if requirement.UserID != uid && !canManage {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized"})
 		return
 	}
@@ -123,7 +126,7 @@ func UpdateRequirement(c *gin.Context) {
 func DeleteRequirement(c *gin.Context) {
 	id := c.Param("id")
 	userID, _ := c.Get("userID") // might be nil if admin not logged in via same flow? No, AuthMiddleware sets it.
-	userRole := c.GetString("role")
+	canManage := rbac.Can(c.GetUint("userID"), rbac.PermListingsManage)
 
 	var requirement models.Requirement
 	if err := config.DB.First(&requirement, id).Error; err != nil {
@@ -139,7 +142,7 @@ func DeleteRequirement(c *gin.Context) {
 		// Assuming AuthMiddleware sets it correctly as uint.
 	}
 
-	if requirement.UserID != uid && userRole != "admin" {
+	if requirement.UserID != uid && !canManage {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to delete this requirement"})
 		return
 	}
@@ -150,15 +153,14 @@ func DeleteRequirement(c *gin.Context) {
 	}
 
 	// Notify if Admin deleted it
-	if userRole == "admin" && requirement.UserID != uid {
-		content := "Your requirement for '" + requirement.Type + "' has been removed by the administrator."
-		notification := models.Notification{
-			UserID:  requirement.UserID,
-			Content: content,
-			Type:    "system",
-			IsRead:  false,
-		}
-		config.DB.Create(&notification)
+	if canManage && requirement.UserID != uid {
+		notify.Send(models.Notification{
+			UserID:   requirement.UserID,
+			Content:  "Your requirement for '" + requirement.Type + "' has been removed by the administrator.",
+			Category: models.NotificationCategoryRequirement,
+			Severity: models.SeverityWarning,
+			Data:     models.JSONMap{"requirement_id": requirement.ID, "type": requirement.Type},
+		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Requirement deleted successfully"})
@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/rbac"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,31}$`)
+
+// GetPermissions lists every permission a role can be given
+func GetPermissions(c *gin.Context) {
+	var permissions []models.Permission
+	config.DB.Order("id").Find(&permissions)
+	c.JSON(http.StatusOK, permissions)
+}
+
+// GetRoles lists staff roles with their permissions and how many users hold them
+func GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	type roleWithCount struct {
+		models.Role
+		Users int64 `json:"users"`
+	}
+	result := make([]roleWithCount, 0, len(roles))
+	for _, role := range roles {
+		var count int64
+		config.DB.Model(&models.User{}).
+			Where("role = ? OR id IN (?)", role.Name,
+				config.DB.Model(&models.UserRoleGrant{}).Select("user_id").Where("role_id = ?", role.ID)).
+			Count(&count)
+		result = append(result, roleWithCount{Role: role, Users: count})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// loadPermissions resolves permission keys, failing on unknown ones
+func loadPermissions(keys []string) ([]models.Permission, error) {
+	permissions := make([]models.Permission, 0)
+	if len(keys) == 0 {
+		return permissions, nil
+	}
+	config.DB.Where("key IN ?", keys).Find(&permissions)
+
+	found := make(map[string]bool)
+	for _, p := range permissions {
+		found[p.Key] = true
+	}
+	for _, key := range keys {
+		if !found[key] {
+			return nil, fmt.Errorf("unknown permission %q", key)
+		}
+	}
+	return permissions, nil
+}
+
+// CreateRole adds a staff role with a set of permissions
+func CreateRole(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Name        string   `json:"name" binding:"required"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.Name = strings.ToLower(strings.TrimSpace(input.Name))
+	if !roleNamePattern.MatchString(input.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role name must be 2-32 lowercase letters, digits or underscores"})
+		return
+	}
+	if rbac.IsAccountRole(input.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role name is reserved for account roles"})
+		return
+	}
+
+	permissions, err := loadPermissions(input.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := models.Role{Name: input.Name, Description: input.Description, Permissions: permissions}
+	if err := config.DB.Create(&role).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "A role with this name already exists"})
+		return
+	}
+
+	recordAudit(adminID, "create_role", "role", role.ID, fmt.Sprintf("%s: %s", role.Name, strings.Join(input.Permissions, ", ")))
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole changes a role's description and replaces its permissions. The
+// admin role always has every permission and cannot be edited.
+func UpdateRole(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var role models.Role
+	if err := config.DB.First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+	if role.Name == "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The admin role cannot be changed"})
+		return
+	}
+
+	var input struct {
+		Description *string  `json:"description"`
+		Permissions []string `json:"permissions" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	permissions, err := loadPermissions(input.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if input.Description != nil {
+			if err := tx.Model(&role).Update("description", *input.Description).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	rbac.InvalidateAll()
+
+	recordAudit(adminID, "update_role", "role", role.ID, fmt.Sprintf("%s: %s", role.Name, strings.Join(input.Permissions, ", ")))
+
+	config.DB.Preload("Permissions").First(&role, role.ID)
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole removes a custom role and its grants. Built-in roles stay.
+func DeleteRole(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var role models.Role
+	if err := config.DB.First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+	if role.IsSystem {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Built-in roles cannot be deleted"})
+		return
+	}
+
+	var holders int64
+	config.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&holders)
+	if holders > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%d users have this as their account role; change it first", holders)})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.UserRoleGrant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+	rbac.InvalidateAll()
+
+	recordAudit(adminID, "delete_role", "role", role.ID, role.Name)
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+}
+
+// GetUserRoles shows a user's account role, granted roles and the permissions they add up to
+func GetUserRoles(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var grants []models.UserRoleGrant
+	config.DB.Preload("Role").Where("user_id = ?", user.ID).Order("id").Find(&grants)
+
+	c.JSON(http.StatusOK, gin.H{
+		"account_role": user.Role,
+		"grants":       grants,
+		"permissions":  rbac.List(user.ID),
+	})
+}
+
+// GrantUserRole gives a user an additional staff role
+func GrantUserRole(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var input struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var role models.Role
+	if err := config.DB.Where("name = ?", input.Role).First(&role).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	grant := models.UserRoleGrant{UserID: user.ID, RoleID: role.ID, GrantedByID: adminID}
+	if err := config.DB.Create(&grant).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already has this role"})
+		return
+	}
+	rbac.Invalidate(user.ID)
+
+	recordAudit(adminID, "grant_role", "user", user.ID, role.Name)
+
+	grant.Role = role
+	c.JSON(http.StatusCreated, grant)
+}
+
+// RevokeUserRole takes a granted role away. Admins cannot revoke their own
+// grants, so nobody locks themselves out by accident.
+func RevokeUserRole(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if uint(userID) == adminID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot revoke your own roles"})
+		return
+	}
+
+	var grant models.UserRoleGrant
+	if err := config.DB.Preload("Role").Where("user_id = ? AND role_id = ?", userID, c.Param("roleId")).First(&grant).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role grant not found"})
+		return
+	}
+
+	if err := config.DB.Delete(&grant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke role"})
+		return
+	}
+	rbac.Invalidate(grant.UserID)
+
+	recordAudit(adminID, "revoke_role", "user", grant.UserID, grant.Role.Name)
+	c.JSON(http.StatusOK, gin.H{"message": "Role revoked"})
+}
+
+// GetMyPermissions returns the signed-in user's permission keys, so the
+// frontend can show only what they may use
+func GetMyPermissions(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	c.JSON(http.StatusOK, gin.H{"permissions": rbac.List(userID)})
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// startSession opens a session for a device and returns the token pair the
+// client should store.
+func startSession(c *gin.Context, user models.User) (gin.H, error) {
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        c.Request.UserAgent(),
+		IPAddress:        c.ClientIP(),
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(refreshTokenTTL),
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return tokenPair(session, user.Role, refreshToken)
+}
+
+func tokenPair(session models.Session, role, refreshToken string) (gin.H, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  session.UserID,
+		"sid":  session.ID,
+		"role": role,
+		"exp":  time.Now().Add(accessTokenTTL).Unix(),
+	})
+	accessToken, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"token":         accessToken,
+		"expires_in":    int(accessTokenTTL.Seconds()),
+		"refresh_token": refreshToken,
+	}, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// revokeUserSessions signs a user out everywhere, e.g. when the account is deactivated
+func revokeUserSessions(userID uint) {
+	config.DB.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", time.Now())
+}
+
+// rotateSession swaps a refresh token for a new one. Presenting a token that
+// was already rotated out means it leaked, so the whole session is revoked.
+func rotateSession(refreshToken string) (models.Session, string, error) {
+	hash := hashToken(refreshToken)
+	now := time.Now()
+
+	var session models.Session
+	if err := config.DB.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		if config.DB.Where("previous_token_hash = ?", hash).First(&session).Error == nil {
+			config.DB.Model(&session).Where("revoked_at IS NULL").Update("revoked_at", now)
+		}
+		return session, "", errInvalidRefreshToken
+	}
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return session, "", errInvalidRefreshToken
+	}
+
+	next, err := randomToken()
+	if err != nil {
+		return session, "", err
+	}
+
+	// Conditional on the old hash so two concurrent refreshes cannot both win
+	result := config.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  hashToken(next),
+			"previous_token_hash": hash,
+			"last_used_at":        now,
+			"expires_at":          now.Add(refreshTokenTTL),
+		})
+	if result.Error != nil {
+		return session, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return session, "", errInvalidRefreshToken
+	}
+
+	return session, next, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token
+func RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, refreshToken, err := rotateSession(input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, errInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please log in again"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		}
+		return
+	}
+
+	// Deactivated users are soft-deleted and will not be found
+	var user models.User
+	if err := config.DB.First(&user, session.UserID).Error; err != nil || user.SuspendedAt != nil {
+		revokeUserSessions(session.UserID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please log in again"})
+		return
+	}
+
+	tokens, err := tokenPair(session, user.Role, refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the session the request was made with
+func Logout(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	sessionID := c.MustGet("sessionID").(uint)
+
+	config.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// GetSessions lists the caller's active sessions, marking the current one
+func GetSessions(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	sessionID := c.MustGet("sessionID").(uint)
+
+	var sessions []models.Session
+	if err := config.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at desc").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type sessionView struct {
+		models.Session
+		Current bool `json:"current"`
+	}
+	views := make([]sessionView, len(sessions))
+	for i, session := range sessions {
+		views[i] = sessionView{Session: session, Current: session.ID == sessionID}
+	}
+
+	c.JSON(http.StatusOK, views)
+}
+
+// RevokeSession signs out one of the caller's devices
+func RevokeSession(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	result := config.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeAllSessions signs the caller out everywhere. With keep_current=true the
+// session making the request stays signed in.
+func RevokeAllSessions(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	sessionID := c.MustGet("sessionID").(uint)
+
+	query := config.DB.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if c.Query("keep_current") == "true" {
+		query = query.Where("id <> ?", sessionID)
+	}
+
+	result := query.Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked", "revoked": result.RowsAffected})
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238). These are what every authenticator app assumes.
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // Steps either side of now that are still accepted
+	totpIssuer = "RJG Property Connect"
+
+	recoveryCodeCount = 10
+)
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func newTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32NoPad.EncodeToString(b), nil
+}
+
+func totpCode(secret string, step int64) (string, error) {
+	key, err := base32NoPad.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000), nil
+}
+
+// matchTOTP returns the time step code is valid for, allowing for clock drift,
+// and whether it matched at all. Steps at or before lastUsed are refused so a
+// code cannot be used twice.
+func matchTOTP(secret, code string, lastUsed int64) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	now := time.Now().Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if step <= lastUsed {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err == nil && hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// provisioningURI is what the enrollment QR code encodes
+func provisioningURI(secret, account string) string {
+	label := url.PathEscape(totpIssuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", totpIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	// Some authenticator apps show "+" literally, so spaces are sent as %20
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
+}
+
+// TOTP secrets are encrypted at rest with TOTP_ENCRYPTION_KEY, or JWT_SECRET
+// when that is not set.
+func totpCipher() (cipher.AEAD, error) {
+	secret := os.Getenv("TOTP_ENCRYPTION_KEY")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET")
+	}
+	key := sha256.Sum256([]byte("totp:" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptTOTPSecret(secret string) (string, error) {
+	gcm, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func decryptTOTPSecret(stored string) (string, error) {
+	gcm, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(stored)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errors.New("malformed totp secret")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// newRecoveryCodes returns codes like "k3f9-x2mq" for the user to write down
+func newRecoveryCodes() ([]string, error) {
+	const alphabet = "abcdefghijkmnpqrstuvwxyz23456789" // 32 symbols, no l, o, 0 or 1
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		for j := range b {
+			b[j] = alphabet[int(b[j])%len(alphabet)]
+		}
+		codes[i] = string(b[:4]) + "-" + string(b[4:])
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) == 8 {
+		code = code[:4] + "-" + code[4:]
+	}
+	return code
+}
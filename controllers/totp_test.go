@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"errors"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// The SHA-1 test vectors from RFC 6238, Appendix B, cut to our six digits.
+func TestTOTPCodeRFC6238(t *testing.T) {
+	secret := base32NoPad.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := totpCode(secret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix() / totpPeriod
+
+	code, _ := totpCode(secret, now)
+	step, ok := matchTOTP(secret, code[:3]+" "+code[3:], 0)
+	if !ok || step != now {
+		t.Fatalf("current code: step %d ok %v, want step %d", step, ok, now)
+	}
+	if _, ok := matchTOTP(secret, code, step); ok {
+		t.Error("code accepted again after its step was used")
+	}
+
+	previous, _ := totpCode(secret, now-totpSkew)
+	if _, ok := matchTOTP(secret, previous, 0); !ok {
+		t.Error("code from the previous step refused despite the allowed skew")
+	}
+	stale, _ := totpCode(secret, now-totpSkew-1)
+	if _, ok := matchTOTP(secret, stale, 0); ok {
+		t.Error("code from outside the skew window accepted")
+	}
+	if _, ok := matchTOTP(secret, "", 0); ok {
+		t.Error("empty code accepted")
+	}
+}
+
+func TestTOTPSecretEncryption(t *testing.T) {
+	t.Setenv("TOTP_ENCRYPTION_KEY", "test-key")
+
+	stored, err := encryptTOTPSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret, err := decryptTOTPSecret(stored); err != nil || secret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("round trip gave %q, %v", secret, err)
+	}
+
+	t.Setenv("TOTP_ENCRYPTION_KEY", "another-key")
+	if _, err := decryptTOTPSecret(stored); err == nil {
+		t.Error("secret decrypted with the wrong key")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	format := regexp.MustCompile(`^[a-km-np-z2-9]{4}-[a-km-np-z2-9]{4}$`)
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("malformed recovery code %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate recovery code %q", code)
+		}
+		seen[code] = true
+	}
+
+	for _, typed := range []string{"K3F9-X2MQ", " k3f9x2mq ", "k3f9 x2mq"} {
+		if got := normalizeRecoveryCode(typed); got != "k3f9-x2mq" {
+			t.Errorf("normalizeRecoveryCode(%q) = %q", typed, got)
+		}
+	}
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+	setupTestDB(t, &models.RecoveryCode{}, &models.TwoFactor{})
+
+	user := models.User{ID: uint(time.Now().UnixNano() % 1_000_000_000)}
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceRecoveryCodes(config.DB, user.ID, codes); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { config.DB.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}) })
+
+	if err := checkSecondFactor(user, "", codes[0]); err != nil {
+		t.Fatalf("unused recovery code refused: %v", err)
+	}
+	if err := checkSecondFactor(user, "", codes[0]); !errors.Is(err, errInvalidSecondCode) {
+		t.Errorf("recovery code accepted twice: %v", err)
+	}
+	if err := checkSecondFactor(user, "", "aaaa-aaaa"); !errors.Is(err, errInvalidSecondCode) {
+		t.Errorf("unknown recovery code: %v", err)
+	}
+	// Codes can be typed without the dash or in capitals
+	typed := codes[1][:4] + codes[1][5:]
+	if err := checkSecondFactor(user, "", typed); err != nil {
+		t.Errorf("recovery code typed without the dash refused: %v", err)
+	}
+}
+
+func TestAuthenticatorCodeSingleUse(t *testing.T) {
+	t.Setenv("TOTP_ENCRYPTION_KEY", "test-key")
+	setupTestDB(t, &models.RecoveryCode{}, &models.TwoFactor{})
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := encryptTOTPSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user := models.User{ID: uint(now.UnixNano() % 1_000_000_000)}
+	if err := config.DB.Create(&models.TwoFactor{UserID: user.ID, Secret: encrypted, EnabledAt: &now}).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { config.DB.Where("user_id = ?", user.ID).Delete(&models.TwoFactor{}) })
+
+	code, _ := totpCode(secret, now.Unix()/totpPeriod)
+	if err := checkSecondFactor(user, code, ""); err != nil {
+		t.Fatalf("current code refused: %v", err)
+	}
+	if err := checkSecondFactor(user, code, ""); !errors.Is(err, errInvalidSecondCode) {
+		t.Errorf("code accepted twice: %v", err)
+	}
+}
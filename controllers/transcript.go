@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transcript is a format-neutral record of a conversation for export.
+type transcript struct {
+	Title        string
+	Details      []string
+	Participants []string
+	Entries      []transcriptEntry
+	GeneratedAt  time.Time
+}
+
+type transcriptEntry struct {
+	Time        time.Time
+	Author      string
+	Text        string
+	System      bool
+	Deleted     bool
+	DeletedAt   *time.Time
+	EditedAt    *time.Time
+	Attachments []string
+}
+
+const transcriptTimeFormat = "02 Jan 2006 15:04"
+
+// transcriptFormat reads the "format" query parameter (pdf, html or txt),
+// defaulting to pdf. It writes the error response itself.
+func transcriptFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", "pdf")
+	switch format {
+	case "pdf", "html", "txt":
+		return format, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "format must be pdf, html or txt"})
+	return "", false
+}
+
+// writeTranscript renders t in the given format as a file download.
+func writeTranscript(c *gin.Context, t transcript, format, baseName string) {
+	var body []byte
+	var contentType string
+	switch format {
+	case "txt":
+		body, contentType = []byte(renderTranscriptText(t)), "text/plain; charset=utf-8"
+	case "html":
+		html, err := renderTranscriptHTML(t)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render transcript"})
+			return
+		}
+		body, contentType = html, "text/html; charset=utf-8"
+	default:
+		var unsupported int
+		body, unsupported = renderTranscriptPDF(t)
+		contentType = "application/pdf"
+		if unsupported > 0 {
+			c.Header("X-Transcript-Unsupported-Characters", fmt.Sprint(unsupported))
+		}
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", baseName, format))
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// transcriptLines is the shared line-oriented layout used by the text and PDF renderers.
+func transcriptLines(t transcript) []string {
+	lines := []string{t.Title}
+	lines = append(lines, t.Details...)
+	lines = append(lines, "Participants: "+strings.Join(t.Participants, ", "))
+	lines = append(lines, "Generated: "+t.GeneratedAt.Format(transcriptTimeFormat), "")
+
+	for _, e := range t.Entries {
+		header := "[" + e.Time.Format(transcriptTimeFormat) + "] " + e.Author
+		if e.System {
+			header = "[" + e.Time.Format(transcriptTimeFormat) + "] *"
+		}
+		text := e.Text
+		if e.Deleted {
+			text = "[message deleted"
+			if e.DeletedAt != nil {
+				text += " " + e.DeletedAt.Format(transcriptTimeFormat)
+			}
+			text += "]"
+			if e.Text != "" {
+				text += " " + e.Text
+			}
+		}
+		if e.EditedAt != nil {
+			text += " (edited " + e.EditedAt.Format(transcriptTimeFormat) + ")"
+		}
+		lines = append(lines, header+": "+text)
+		for _, a := range e.Attachments {
+			lines = append(lines, "    Attachment: "+a)
+		}
+	}
+	return lines
+}
+
+func renderTranscriptText(t transcript) string {
+	return strings.Join(transcriptLines(t), "\n") + "\n"
+}
+
+var transcriptHTMLTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"ts": func(t time.Time) string { return t.Format(transcriptTimeFormat) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>
+body{font-family:Arial,sans-serif;max-width:800px;margin:2em auto;color:#222}
+.meta{color:#555;margin:0}
+.entry{margin:.6em 0}
+.time{color:#888;font-size:.85em}
+.system{color:#666;font-style:italic}
+.deleted{color:#a00}
+.edited{color:#888;font-size:.85em}
+</style></head><body>
+<h1>{{.Title}}</h1>
+{{range .Details}}<p class="meta">{{.}}</p>{{end}}
+<p class="meta">Participants: {{range $i, $p := .Participants}}{{if $i}}, {{end}}{{$p}}{{end}}</p>
+<p class="meta">Generated: {{ts .GeneratedAt}}</p>
+<hr>
+{{range .Entries}}<div class="entry{{if .System}} system{{end}}">
+<span class="time">{{ts .Time}}</span> {{if not .System}}<strong>{{.Author}}</strong>: {{end}}{{if .Deleted}}<span class="deleted">[message deleted{{if .DeletedAt}} {{ts .DeletedAt}}{{end}}]</span> {{end}}{{.Text}}{{if .EditedAt}} <span class="edited">(edited {{ts .EditedAt}})</span>{{end}}
+{{range .Attachments}}<div class="meta">Attachment: {{.}}</div>{{end}}
+</div>
+{{end}}</body></html>
+`))
+
+func renderTranscriptHTML(t transcript) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := transcriptHTMLTemplate.Execute(&buf, t); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exportName returns the name to show for a user in an export. Anonymized users
+// appear under their alias to other participants; admins see real names.
+func exportName(user models.User, alias string, viewerID uint, isAdmin bool) string {
+	if isAdmin || user.ID == viewerID || user.PublicPreference != "Anonymized" {
+		return user.Name
+	}
+	return alias
+}
+
+// recordAudit stores an audit entry for a sensitive admin action.
+func recordAudit(actorID uint, action, targetType string, targetID uint, details string) {
+	entry := models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+	}
+	config.DB.Create(&entry)
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/rbac"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportThread downloads a chat thread's history as PDF, HTML or plain text.
+// Admins may export any thread; every admin export is audited.
+func (cc *ChatController) ExportThread(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	isAdmin := rbac.Can(userID, rbac.PermChatModerate)
+
+	format, ok := transcriptFormat(c)
+	if !ok {
+		return
+	}
+
+	var thread models.ChatThread
+	if err := config.DB.Preload("Property").Preload("Participants.User").First(&thread, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+
+	var me *models.ChatParticipant
+	for i := range thread.Participants {
+		if thread.Participants[i].UserID == userID {
+			me = &thread.Participants[i]
+		}
+	}
+	if me == nil && !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	clearedUpTo := uint(0)
+	if me != nil && !isAdmin {
+		// Respect the caller's own delete-for-me history
+		clearedUpTo = me.ClearedUpToID
+	}
+
+	// Inquiry threads use the inquiry's aliases
+	aliases := loadInquiryAliases(thread.ID)
+	names := make(map[uint]string)
+	t := transcript{Title: thread.Title, GeneratedAt: time.Now()}
+	for _, p := range thread.Participants {
+		alias, ok := aliases.name(thread.ID, p.User)
+		if !ok {
+			alias = "Member " + fmt.Sprint(p.UserID+200)
+		}
+		name := exportName(p.User, alias, userID, isAdmin)
+		names[p.UserID] = name
+		t.Participants = append(t.Participants, name+" ("+p.Role+")")
+	}
+	if t.Title == "" {
+		t.Title = fmt.Sprintf("Conversation #%d", thread.ID)
+	}
+	if thread.Property != nil {
+		t.Details = append(t.Details, "Property: "+thread.Property.Title)
+	}
+
+	entries, err := threadTranscriptEntries(thread.ID, clearedUpTo, names, userID, isAdmin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+	t.Entries = entries
+
+	if isAdmin {
+		recordAudit(userID, "export_chat_thread", "chat_thread", thread.ID, "format="+format)
+	}
+
+	writeTranscript(c, t, format, fmt.Sprintf("chat-%d", thread.ID))
+}
+
+// ExportInquiry downloads an inquiry and its messages as PDF, HTML or plain text.
+// Admins may export any inquiry; every admin export is audited.
+func ExportInquiry(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	isAdmin := rbac.Can(userID, rbac.PermChatModerate)
+
+	format, ok := transcriptFormat(c)
+	if !ok {
+		return
+	}
+
+	var inquiry models.Inquiry
+	if err := config.DB.Preload("Property").Preload("Seeker").Preload("Owner").
+		First(&inquiry, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inquiry not found"})
+		return
+	}
+
+	isParticipant := inquiry.SeekerID == userID || inquiry.OwnerID == userID
+	if !isParticipant && !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	seeker := exportName(inquiry.Seeker, "Requester "+fmt.Sprint(inquiry.Seeker.ID+500), userID, isAdmin)
+	owner := exportName(inquiry.Owner, "Owner "+fmt.Sprint(inquiry.Owner.ID+200), userID, isAdmin)
+
+	t := transcript{
+		Title:        fmt.Sprintf("Inquiry #%d: %s", inquiry.ID, inquiry.Property.Title),
+		Participants: []string{seeker + " (seeker)", owner + " (owner)"},
+		GeneratedAt:  time.Now(),
+	}
+	t.Details = append(t.Details, "Status: "+inquiry.Status)
+	if inquiry.ExpectedDate != "" {
+		t.Details = append(t.Details, "Expected date: "+inquiry.ExpectedDate)
+	}
+	if inquiry.Budget > 0 {
+		t.Details = append(t.Details, fmt.Sprintf("Budget: %.0f", inquiry.Budget))
+	}
+
+	if inquiry.ChatThreadID != nil {
+		clearedUpTo := uint(0)
+		if isParticipant && !isAdmin {
+			// Respect the caller's own delete-for-me history, as ExportThread does
+			var me models.ChatParticipant
+			if err := config.DB.Where("thread_id = ? AND user_id = ?", *inquiry.ChatThreadID, userID).First(&me).Error; err == nil {
+				clearedUpTo = me.ClearedUpToID
+			}
+		}
+
+		names := map[uint]string{inquiry.SeekerID: seeker, inquiry.OwnerID: owner}
+		entries, err := threadTranscriptEntries(*inquiry.ChatThreadID, clearedUpTo, names, userID, isAdmin)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+			return
+		}
+		t.Entries = entries
+	} else {
+		if !isAdmin {
+			newContactMasker().inquiry(&inquiry)
+		}
+		t.Entries = append(t.Entries, transcriptEntry{Time: inquiry.CreatedAt, Author: seeker, Text: inquiry.InitialMessage})
+	}
+
+	if isAdmin {
+		recordAudit(userID, "export_inquiry", "inquiry", inquiry.ID, "format="+format)
+	}
+
+	writeTranscript(c, t, format, fmt.Sprintf("inquiry-%d", inquiry.ID))
+}
+
+// threadTranscriptEntries loads a thread's messages after clearedUpTo as
+// transcript entries. Senders missing from names, such as former participants,
+// are shown under their anonymized alias where they asked for one.
+func threadTranscriptEntries(threadID, clearedUpTo uint, names map[uint]string, viewerID uint, isAdmin bool) ([]transcriptEntry, error) {
+	var messages []models.ChatMessage
+	if err := config.DB.Preload("Sender").Preload("Attachments").
+		Where("thread_id = ? AND id > ?", threadID, clearedUpTo).
+		Order("created_at ASC, id ASC").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	// Admins see messages as written; participants see what the chat shows them
+	if !isAdmin {
+		newContactMasker().messages(messages)
+	}
+
+	var entries []transcriptEntry
+	for _, m := range messages {
+		name, ok := names[m.SenderID]
+		if !ok {
+			name = exportName(m.Sender, "Member "+fmt.Sprint(m.SenderID+200), viewerID, isAdmin)
+		}
+
+		entry := transcriptEntry{
+			Time:    m.CreatedAt,
+			Author:  name,
+			Text:    m.Content,
+			System:  m.Type == models.MessageTypeSystem,
+			Deleted: m.IsDeleted,
+		}
+		if m.IsEdited {
+			entry.EditedAt = m.EditedAt
+		}
+		if m.IsDeleted {
+			// Admins see what was removed; participants only see the marker
+			entry.DeletedAt = m.DeletedAt
+			if !isAdmin {
+				entry.Text = ""
+			}
+		}
+		if !m.IsDeleted || isAdmin {
+			for _, a := range m.Attachments {
+				entry.Attachments = append(entry.Attachments, fmt.Sprintf("%s (%s, %d KB)", a.FileName, a.Kind, (a.Size+1023)/1024))
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A deliberately small PDF writer: transcripts are plain wrapped text, so the
+// standard Helvetica fonts are used and no third-party dependency is needed.
+// They only cover WinAnsi; anything else is counted and reported as lost.
+const (
+	pdfPageWidth    = 595 // A4 in points
+	pdfPageHeight   = 842
+	pdfMargin       = 50
+	pdfFontSize     = 10
+	pdfTitleSize    = 14
+	pdfLeading      = 14
+	pdfWrapColumns  = 90
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLeading
+)
+
+// renderTranscriptPDF returns the PDF and how many characters the standard
+// fonts could not show. Those are drawn as '?' and a note near the top points
+// to the HTML and text exports, which keep every character.
+func renderTranscriptPDF(t transcript) ([]byte, int) {
+	lines := transcriptLines(t)
+
+	unsupported := 0
+	for _, line := range lines {
+		unsupported += pdfUnsupportedChars(line)
+	}
+	if unsupported > 0 {
+		note := fmt.Sprintf("Note: %d characters cannot be shown in PDF and appear as '?'. Export as HTML or text for the full transcript.", unsupported)
+		lines = append([]string{lines[0], note}, lines[1:]...)
+	}
+
+	// The title is drawn in bold; everything else is wrapped body text
+	var body []string
+	for _, line := range lines[1:] {
+		body = append(body, wrapPDFLine(line, pdfWrapColumns)...)
+	}
+
+	var pages [][]string
+	for len(body) > 0 {
+		n := pdfLinesPerPage
+		if len(pages) == 0 {
+			n -= 2 // Room for the title
+		}
+		if n > len(body) {
+			n = len(body)
+		}
+		pages = append(pages, body[:n])
+		body = body[n:]
+	}
+	if len(pages) == 0 {
+		pages = append(pages, nil)
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(content string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// Objects 1-4 are fixed; each page then takes a page and a content object
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range pages {
+		var stream bytes.Buffer
+		y := pdfPageHeight - pdfMargin
+		if i == 0 {
+			fmt.Fprintf(&stream, "BT /F2 %d Tf %d %d Td (%s) Tj ET\n", pdfTitleSize, pdfMargin, y, pdfEscape(lines[0]))
+			y -= 2 * pdfLeading
+		}
+		fmt.Fprintf(&stream, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, y)
+		for _, line := range page {
+			fmt.Fprintf(&stream, "(%s) Tj T*\n", pdfEscape(line))
+		}
+		fmt.Fprintf(&stream, "ET\n")
+		fmt.Fprintf(&stream, "BT /F1 8 Tf %d %d Td (Page %d of %d) Tj ET\n", pdfMargin, pdfMargin/2, i+1, len(pages))
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", stream.Len(), stream.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes(), unsupported
+}
+
+// wrapPDFLine breaks a line on spaces so it fits within width characters,
+// hard-splitting words that are longer than a full line.
+func wrapPDFLine(line string, width int) []string {
+	runes := []rune(line)
+	if len(runes) <= width {
+		return []string{line}
+	}
+
+	var out []string
+	for len(runes) > width {
+		cut := width
+		for i := width; i > width/2; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		out = append(out, string(runes[:cut]))
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+		// Indent continuation lines so wrapped messages stay readable
+		runes = append([]rune("    "), runes...)
+	}
+	return append(out, string(runes))
+}
+
+// pdfEscape encodes s as a PDF literal string in WinAnsi. Characters the
+// standard fonts cannot show become '?'.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteString(pdfEncodeRune(r))
+	}
+	return b.String()
+}
+
+// pdfUnsupportedChars counts the characters in s that pdfEscape replaces with '?'.
+func pdfUnsupportedChars(s string) int {
+	n := 0
+	for _, r := range s {
+		if r != '?' && pdfEncodeRune(r) == "?" {
+			n++
+		}
+	}
+	return n
+}
+
+func pdfEncodeRune(r rune) string {
+	switch {
+	case r == '(' || r == ')' || r == '\\':
+		return "\\" + string(r)
+	case r == '\t':
+		return "    "
+	case r < 0x20:
+		return " "
+	case r < 0x80:
+		return string(r)
+	case r >= 0xA0 && r <= 0xFF:
+		return fmt.Sprintf("\\%03o", r)
+	case winAnsiExtras[r] != 0:
+		return fmt.Sprintf("\\%03o", winAnsiExtras[r])
+	}
+	if sub, ok := pdfSubstitutes[r]; ok {
+		return sub
+	}
+	return "?"
+}
+
+// winAnsiExtras maps the characters WinAnsi places in 0x80-0x9F.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// pdfSubstitutes spells out common characters WinAnsi lacks.
+var pdfSubstitutes = map[rune]string{
+	'₹':      "Rs.",
+	'−':      "-",
+	'\u200b': "",
+}
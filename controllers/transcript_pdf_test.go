@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPDFEscape(t *testing.T) {
+	tests := []struct {
+		in, want    string
+		unsupported int
+	}{
+		{"Visit (today)?", `Visit \(today\)?`, 0},
+		{"café", `caf\351`, 0},
+		{"“Quoted” – €5", `\223Quoted\224 \226 \2005`, 0},
+		{"₹25 lakh", "Rs.25 lakh", 0},
+		{"नमस्ते 👋", "?????? ?", 7},
+	}
+	for _, tt := range tests {
+		if got := pdfEscape(tt.in); got != tt.want {
+			t.Errorf("pdfEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := pdfUnsupportedChars(tt.in); got != tt.unsupported {
+			t.Errorf("pdfUnsupportedChars(%q) = %d, want %d", tt.in, got, tt.unsupported)
+		}
+	}
+}
+
+func TestRenderTranscriptPDFReportsLostCharacters(t *testing.T) {
+	tr := transcript{
+		Title:       "Inquiry #1",
+		GeneratedAt: time.Now(),
+		Entries:     []transcriptEntry{{Time: time.Now(), Author: "Asha", Text: "नमस्ते"}},
+	}
+
+	pdf, unsupported := renderTranscriptPDF(tr)
+	if unsupported != 6 {
+		t.Errorf("reported %d unsupported characters, want 6", unsupported)
+	}
+	if !bytes.Contains(pdf, []byte("Export as HTML or text")) {
+		t.Error("PDF does not tell the reader that characters were lost")
+	}
+
+	tr.Entries[0].Text = "Hello"
+	if pdf, unsupported = renderTranscriptPDF(tr); unsupported != 0 || bytes.Contains(pdf, []byte("Export as HTML")) {
+		t.Error("note added to a transcript with nothing lost")
+	}
+}
@@ -0,0 +1,554 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"realstate-backend/config"
+	"realstate-backend/middleware"
+	"realstate-backend/models"
+	"realstate-backend/notify"
+	"realstate-backend/rbac"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	challengeTTL         = 5 * time.Minute
+	challengeMaxAttempts = 5
+)
+
+var (
+	errInvalidChallenge  = errors.New("sign-in has expired, please log in again")
+	errInvalidSecondCode = errors.New("invalid authentication code")
+)
+
+// Codes tried per account across all of its challenges; a fresh challenge only
+// costs a password, so the per-challenge limit alone does not stop guessing.
+var twoFactorLimiter = middleware.NewLimiter(10, time.Hour)
+
+// finishLogin is the last step of every sign-in method. Accounts with 2FA get
+// a challenge token to exchange at /auth/2fa/verify; staff without it get one
+// to enroll with first. Everyone else gets a session straight away.
+func finishLogin(c *gin.Context, user models.User, profile gin.H) {
+	if user.TwoFactorEnabled || rbac.IsStaff(user.ID) {
+		token, err := createChallenge(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor sign-in"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"setup_required":      !user.TwoFactorEnabled,
+			"challenge_token":     token,
+			"expires_in":          int(challengeTTL.Seconds()),
+		})
+		return
+	}
+
+	response, err := startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	response["user"] = profile
+	c.JSON(http.StatusOK, response)
+}
+
+// loginProfile is the user object returned once 2FA completes. Staff get their
+// permissions too, as AdminLogin would have returned.
+func loginProfile(user models.User) gin.H {
+	profile := gin.H{
+		"id":    user.ID,
+		"name":  user.Name,
+		"role":  user.Role,
+		"email": user.Email,
+		"phone": user.Phone,
+	}
+	if rbac.IsStaff(user.ID) {
+		profile["permissions"] = rbac.List(user.ID)
+	}
+	return profile
+}
+
+func createChallenge(userID uint) (string, error) {
+	raw, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	challenge := models.TwoFactorChallenge{
+		UserID:    userID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(challengeTTL),
+	}
+	return raw, config.DB.Create(&challenge).Error
+}
+
+func loadChallenge(raw string) (models.TwoFactorChallenge, models.User, error) {
+	var challenge models.TwoFactorChallenge
+	var user models.User
+	if err := config.DB.Where("token_hash = ?", hashToken(raw)).First(&challenge).Error; err != nil {
+		return challenge, user, errInvalidChallenge
+	}
+	if challenge.ConsumedAt != nil || time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= challengeMaxAttempts {
+		return challenge, user, errInvalidChallenge
+	}
+	if err := config.DB.First(&user, challenge.UserID).Error; err != nil || user.SuspendedAt != nil {
+		return challenge, user, errInvalidChallenge
+	}
+	return challenge, user, nil
+}
+
+// failChallenge counts a wrong code against the challenge
+func failChallenge(challenge models.TwoFactorChallenge) {
+	config.DB.Model(&models.TwoFactorChallenge{}).
+		Where("id = ? AND attempts < ?", challenge.ID, challengeMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+}
+
+// allowSecondFactorAttempt counts a code entered for the user and writes the
+// 429 itself once they are locked out.
+func allowSecondFactorAttempt(c *gin.Context, userID uint) bool {
+	if ok, retryAfter := twoFactorLimiter.Allow(fmt.Sprint(userID)); !ok {
+		middleware.TooManyRequests(c, retryAfter)
+		return false
+	}
+	return true
+}
+
+// consumeChallenge marks the challenge used; false if another request got there first
+func consumeChallenge(challenge models.TwoFactorChallenge) bool {
+	result := config.DB.Model(&models.TwoFactorChallenge{}).
+		Where("id = ? AND consumed_at IS NULL", challenge.ID).Update("consumed_at", time.Now())
+	return result.RowsAffected == 1
+}
+
+// beginEnrollment gives the user a new secret to add to their authenticator app.
+// It does nothing to an already enabled authenticator.
+func beginEnrollment(user models.User) (gin.H, error) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptTOTPSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var tf models.TwoFactor
+	config.DB.Where("user_id = ?", user.ID).First(&tf)
+	if tf.EnabledAt != nil {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	tf.UserID = user.ID
+	tf.Secret = encrypted
+	tf.LastUsedStep = 0
+	if err := config.DB.Save(&tf).Error; err != nil {
+		return nil, err
+	}
+
+	return gin.H{"secret": secret, "otpauth_uri": provisioningURI(secret, user.Email)}, nil
+}
+
+// confirmEnrollment turns 2FA on once the user enters a code from the new
+// authenticator, and returns their recovery codes.
+func confirmEnrollment(user models.User, code string) ([]string, error) {
+	var tf models.TwoFactor
+	if err := config.DB.Where("user_id = ?", user.ID).First(&tf).Error; err != nil || tf.EnabledAt != nil {
+		return nil, errors.New("start two-factor setup first")
+	}
+	secret, err := decryptTOTPSecret(tf.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := matchTOTP(secret, code, tf.LastUsedStep)
+	if !ok {
+		return nil, errInvalidSecondCode
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&tf).Updates(map[string]interface{}{"enabled_at": now, "last_used_step": step}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("two_factor_enabled", true).Error; err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, user.ID, codes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	rows := make([]models.RecoveryCode, len(codes))
+	for i, code := range codes {
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(code)}
+	}
+	return tx.Create(&rows).Error
+}
+
+// checkSecondFactor accepts either a current authenticator code or an unused recovery code
+func checkSecondFactor(user models.User, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		result := config.DB.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(recoveryCode))).
+			Update("used_at", time.Now())
+		if result.RowsAffected == 0 {
+			return errInvalidSecondCode
+		}
+		return nil
+	}
+
+	var tf models.TwoFactor
+	if err := config.DB.Where("user_id = ? AND enabled_at IS NOT NULL", user.ID).First(&tf).Error; err != nil {
+		return errInvalidSecondCode
+	}
+	secret, err := decryptTOTPSecret(tf.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok := matchTOTP(secret, code, tf.LastUsedStep)
+	if !ok {
+		return errInvalidSecondCode
+	}
+	// Conditional so the same code cannot be replayed by a parallel request
+	result := config.DB.Model(&models.TwoFactor{}).Where("id = ? AND last_used_step < ?", tf.ID, step).Update("last_used_step", step)
+	if result.RowsAffected == 0 {
+		return errInvalidSecondCode
+	}
+	return nil
+}
+
+func clearTwoFactor(userID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", false).Error
+	})
+}
+
+// VerifyTwoFactor completes a sign-in with an authenticator or recovery code
+func VerifyTwoFactor(c *gin.Context) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Code == "" && input.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	challenge, user, err := loadChallenge(input.ChallengeToken)
+	if err != nil || !user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidChallenge.Error()})
+		return
+	}
+	if !allowSecondFactorAttempt(c, user.ID) {
+		return
+	}
+
+	if err := checkSecondFactor(user, input.Code, input.RecoveryCode); err != nil {
+		failChallenge(challenge)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidSecondCode.Error()})
+		return
+	}
+	if !consumeChallenge(challenge) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidChallenge.Error()})
+		return
+	}
+	twoFactorLimiter.Reset(fmt.Sprint(user.ID))
+
+	response, err := startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	response["user"] = loginProfile(user)
+	if input.RecoveryCode != "" {
+		var remaining int64
+		config.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining)
+		response["recovery_codes_remaining"] = remaining
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// StartChallengeEnrollment lets an admin who has no authenticator yet set one
+// up in the middle of signing in
+func StartChallengeEnrollment(c *gin.Context) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, user, err := loadChallenge(input.ChallengeToken)
+	if err != nil || user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidChallenge.Error()})
+		return
+	}
+
+	enrollment, err := beginEnrollment(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmChallengeEnrollment enables the new authenticator and finishes signing in
+func ConfirmChallengeEnrollment(c *gin.Context) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, user, err := loadChallenge(input.ChallengeToken)
+	if err != nil || user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidChallenge.Error()})
+		return
+	}
+	if !allowSecondFactorAttempt(c, user.ID) {
+		return
+	}
+
+	codes, err := confirmEnrollment(user, input.Code)
+	if err != nil {
+		failChallenge(challenge)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !consumeChallenge(challenge) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidChallenge.Error()})
+		return
+	}
+	twoFactorLimiter.Reset(fmt.Sprint(user.ID))
+
+	response, err := startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	response["user"] = loginProfile(user)
+	response["recovery_codes"] = codes
+	c.JSON(http.StatusOK, response)
+}
+
+// GetTwoFactorStatus reports whether the caller has 2FA and how many recovery codes are left
+func GetTwoFactorStatus(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var remaining int64
+	config.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&remaining)
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TwoFactorEnabled,
+		"required":                 rbac.IsStaff(userID),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// SetupTwoFactor starts enrolling an authenticator for the signed-in user
+func SetupTwoFactor(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	enrollment, err := beginEnrollment(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// EnableTwoFactor confirms the authenticator with a code and returns recovery codes
+func EnableTwoFactor(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !allowSecondFactorAttempt(c, user.ID) {
+		return
+	}
+
+	codes, err := confirmEnrollment(user, input.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	twoFactorLimiter.Reset(fmt.Sprint(user.ID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// DisableTwoFactor turns 2FA off after checking a current code. Staff cannot opt out.
+func DisableTwoFactor(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || (input.Code == "" && input.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if rbac.IsStaff(user.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for staff accounts"})
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !allowSecondFactorAttempt(c, user.ID) {
+		return
+	}
+	if err := checkSecondFactor(user, input.Code, input.RecoveryCode); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidSecondCode.Error()})
+		return
+	}
+	twoFactorLimiter.Reset(fmt.Sprint(user.ID))
+
+	if err := clearTwoFactor(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes after checking a current code
+func RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil || !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !allowSecondFactorAttempt(c, user.ID) {
+		return
+	}
+	if err := checkSecondFactor(user, input.Code, ""); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidSecondCode.Error()})
+		return
+	}
+	twoFactorLimiter.Reset(fmt.Sprint(user.ID))
+
+	codes, err := newRecoveryCodes()
+	if err == nil {
+		err = replaceRecoveryCodes(config.DB, user.ID, codes)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserTwoFactor removes a user's authenticator, e.g. after they lost their
+// phone and recovery codes. Their sessions are signed out and the reset is audited.
+func ResetUserTwoFactor(c *gin.Context) {
+	adminID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.ID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ask another admin to reset your two-factor authentication"})
+		return
+	}
+
+	if err := clearTwoFactor(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	revokeUserSessions(user.ID)
+	recordAudit(adminID, "reset_2fa", "user", user.ID, input.Reason)
+
+	notify.Send(models.Notification{
+		UserID:   user.ID,
+		Content:  "Two-factor authentication on your account was reset by an administrator. Set it up again from your security settings.",
+		Category: models.NotificationCategoryAccount,
+		Severity: models.SeverityWarning,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
@@ -28,8 +28,9 @@ func UpdateProfile(c *gin.Context) {
 		Phone              string `json:"phone"`
 		PublicPreference   string `json:"public_preference"`
 		ContactPreference  string `json:"contact_preference"`
-		EmailNotifications bool   `json:"email_notifications"`
-		InAppNotifications bool   `json:"in_app_notifications"`
+		EmailNotifications *bool  `json:"email_notifications"`
+		InAppNotifications *bool  `json:"in_app_notifications"`
+		SMSNotifications   *bool  `json:"sms_notifications"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -43,18 +44,45 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	updates := models.User{
-		Name:               input.Name,
-		Phone:              input.Phone,
-		PublicPreference:   input.PublicPreference,
-		ContactPreference:  input.ContactPreference,
-		EmailNotifications: input.EmailNotifications,
-		InAppNotifications: input.InAppNotifications,
+	updates := map[string]interface{}{}
+	if input.Name != "" {
+		updates["name"] = input.Name
+	}
+	if input.Phone != "" {
+		phone, err := models.NormalizePhone(input.Phone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+			return
+		}
+		// A new number has to be verified again over OTP
+		if phone != user.Phone {
+			updates["phone"] = phone
+			updates["phone_verified"] = false
+			updates["phone_verified_at"] = nil
+		}
+	}
+	if input.PublicPreference != "" {
+		updates["public_preference"] = input.PublicPreference
+	}
+	if input.ContactPreference != "" {
+		updates["contact_preference"] = input.ContactPreference
+	}
+	// Channel preferences are pointers so they can be switched off
+	if input.EmailNotifications != nil {
+		updates["email_notifications"] = *input.EmailNotifications
+	}
+	if input.InAppNotifications != nil {
+		updates["in_app_notifications"] = *input.InAppNotifications
+	}
+	if input.SMSNotifications != nil {
+		updates["sms_notifications"] = *input.SMSNotifications
 	}
 
-	if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
-		return
+	if len(updates) > 0 {
+		if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, user)
@@ -68,6 +96,7 @@ func DeactivateAccount(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate account"})
 		return
 	}
+	revokeUserSessions(userID.(uint))
 
 	c.JSON(http.StatusOK, gin.H{"message": "Account deactivated successfully"})
 }
@@ -6,8 +6,11 @@ import (
 	"os"
 	"realstate-backend/config"
 	"realstate-backend/models"
+	"realstate-backend/notify"
+	"realstate-backend/rbac"
 	"realstate-backend/routes"
 	"realstate-backend/ws"
+	"strings"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -21,21 +24,65 @@ func main() {
 
 	config.ConnectDB()
 
+	// Checked before migrating so accounts that predate verification can be grandfathered
+	hadEmailVerification := config.DB.Migrator().HasColumn(&models.User{}, "email_verified")
+	hadSuspensions := config.DB.Migrator().HasColumn(&models.User{}, "suspended_at")
+
 	// Auto Migration
-	err := config.DB.AutoMigrate(&models.User{}, &models.Property{}, &models.Requirement{}, &models.Payment{}, &models.Inquiry{}, &models.InquiryMessage{}, &models.Notification{}, &models.SiteConfig{}, &models.PageContent{}, &models.Bookmark{}, &models.ChatThread{}, &models.ChatMessage{})
+	err := config.DB.AutoMigrate(&models.User{}, &models.Property{}, &models.Requirement{}, &models.Payment{}, &models.Inquiry{}, &models.InquiryMessage{}, &models.Notification{}, &models.SiteConfig{}, &models.PageContent{}, &models.Bookmark{}, &models.ChatThread{}, &models.ChatParticipant{}, &models.ChatMessage{}, &models.ChatAttachment{}, &models.UserBlock{}, &models.Report{}, &models.AuditLog{}, &models.ReplyTemplate{}, &models.NotificationDelivery{}, &models.NotificationPreference{}, &models.NotificationDigestItem{}, &models.PushSubscription{}, &models.Broadcast{}, &models.BroadcastRecipient{}, &models.Session{}, &models.UserToken{}, &models.PhoneOTP{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.TwoFactorChallenge{}, &models.Permission{}, &models.Role{}, &models.UserRoleGrant{}, &models.RoleApplication{}, &models.ApplicationDocument{})
 	if err != nil {
 		log.Fatal("Failed to migrate data: ", err)
 	}
+	config.MigratePhoneNumbers()
+	config.CreateSearchIndexes()
+	config.MigrateChatParticipants()
+	config.MigrateInquiryThreads()
+	config.MigrateNotificationCategories()
+	if !hadEmailVerification {
+		config.GrandfatherVerifiedEmails()
+	}
+	if !hadSuspensions {
+		config.MigrateSuspensions()
+	}
 
 	// Seed Data
 	config.SeedData()
+	rbac.Seed()
 
 	// Initialize WebSocket Hub
 	hub := ws.NewHub()
 	go hub.Run()
+	notify.SetHub(hub)
+	notify.SetDispatcher(notify.DispatcherFromEnv())
+	mailer := notify.MailerFromEnv()
+	if mailer == nil {
+		log.Println("WARNING: SMTP_HOST is not set; verification and password reset emails will not be sent (set MAILER=log in development)")
+	}
+	notify.SetMailer(mailer)
+	smsProvider := notify.SMSProviderFromEnv()
+	if smsProvider == nil {
+		log.Println("WARNING: SMS_PROVIDER is not set; phone sign-in and verification codes are disabled (set SMS_PROVIDER=fake in development)")
+	}
+	notify.SetSMSProvider(smsProvider)
+	go notify.StartDigestScheduler()
+	go notify.StartBroadcastScheduler()
 
 	r := gin.Default()
 
+	// Only believe X-Forwarded-For from our own proxies, otherwise clients can
+	// pick their IP and walk around the per-IP rate limits. Behind a platform
+	// load balancer, TRUSTED_PLATFORM names the header it sets, e.g. X-Real-IP.
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES: ", err)
+	}
+	r.TrustedPlatform = os.Getenv("TRUSTED_PLATFORM")
+
 	// CORS Configuration
 	r.Use(cors.New(cors.Config{
 		AllowOrigins: []string{
@@ -49,7 +96,7 @@ func main() {
 		},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-God-Key"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "X-Transcript-Unsupported-Characters"},
 		AllowCredentials: true,
 	}))
 
@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/rbac"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -56,17 +60,48 @@ func AuthMiddleware() gin.HandlerFunc {
 			fmt.Sscanf(v, "%d", &userID)
 		}
 
+		// Access tokens belong to a session, which may have been revoked since
+		sid, _ := claims["sid"].(float64)
+		var session models.Session
+		if sid == 0 || config.DB.Select("id", "user_id", "revoked_at", "expires_at").First(&session, uint(sid)).Error != nil ||
+			session.UserID != userID || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been signed out"})
+			c.Abort()
+			return
+		}
+
 		c.Set("userID", userID)
+		c.Set("sessionID", session.ID)
 		c.Set("role", claims["role"])
 		c.Next()
 	}
 }
 
-func AdminOnly() gin.HandlerFunc {
+// RequirePermission lets the request through only if the user holds all of
+// perms. Must run after AuthMiddleware.
+func RequirePermission(perms ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		if !rbac.Can(c.GetUint("userID"), perms...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to do this"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail blocks users who have not confirmed their email address.
+// Staff are let through. Must run after AuthMiddleware.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rbac.IsStaff(c.GetUint("userID")) {
+			c.Next()
+			return
+		}
+
+		var user models.User
+		if err := config.DB.Select("id", "email_verified").First(&user, c.GetUint("userID")).Error; err != nil || !user.EmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address first", "code": "email_unverified"})
 			c.Abort()
 			return
 		}
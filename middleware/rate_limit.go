@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+// Limiter counts events per key in fixed windows. Counters are kept in
+// memory, per process.
+type Limiter struct {
+	limit   int
+	window  time.Duration
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+// NewLimiter allows each key at most limit events per window.
+func NewLimiter(limit int, window time.Duration) *Limiter {
+	return &Limiter{limit: limit, window: window, windows: make(map[string]*rateWindow)}
+}
+
+// Allow records an event for key. It reports whether the key is still within
+// its limit and, if not, how long until the window resets.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop finished windows now and then so the map does not grow forever
+	if len(l.windows) > 10000 {
+		for k, w := range l.windows {
+			if now.After(w.reset) {
+				delete(l.windows, k)
+			}
+		}
+	}
+	w, ok := l.windows[key]
+	if !ok || now.After(w.reset) {
+		w = &rateWindow{reset: now.Add(l.window)}
+		l.windows[key] = w
+	}
+	w.count++
+	if w.count > l.limit {
+		return false, w.reset.Sub(now)
+	}
+	return true, 0
+}
+
+// Reset forgets the events recorded for key, e.g. after a successful sign-in.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	delete(l.windows, key)
+	l.mu.Unlock()
+}
+
+// TooManyRequests writes the 429 response for a limiter that refused a request.
+func TooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", fmt.Sprint(int(retryAfter.Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later"})
+	c.Abort()
+}
+
+// RateLimit allows each client IP at most limit requests per window on the
+// routes it is attached to. The client IP only honours X-Forwarded-For from
+// the proxies the engine trusts.
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	limiter := NewLimiter(limit, window)
+
+	return func(c *gin.Context) {
+		if ok, retryAfter := limiter.Allow(c.ClientIP() + " " + c.FullPath()); !ok {
+			TooManyRequests(c, retryAfter)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditLog records sensitive admin actions, such as reading private conversations.
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActorID    uint      `gorm:"index" json:"actor_id"`
+	Actor      User      `gorm:"foreignKey:ActorID" json:"actor"`
+	Action     string    `gorm:"not null" json:"action"` // e.g. 'export_chat_thread'
+	TargetType string    `json:"target_type"`            // e.g. 'chat_thread', 'inquiry'
+	TargetID   uint      `json:"target_id"`
+	Details    string    `gorm:"type:text" json:"details"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// UserBlock stops BlockedID from reaching BlockerID through chat or inquiries.
+type UserBlock struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	BlockerID uint      `gorm:"uniqueIndex:idx_user_block;not null" json:"blocker_id"`
+	BlockedID uint      `gorm:"uniqueIndex:idx_user_block;index;not null" json:"blocked_id"`
+	Blocked   User      `gorm:"foreignKey:BlockedID" json:"blocked"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Broadcast is an admin announcement sent as a notification to every user in a segment.
+type Broadcast struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	Title       string           `gorm:"not null" json:"title"` // Internal name shown in the admin list
+	Content     string           `gorm:"type:text;not null" json:"content"`
+	Category    string           `gorm:"default:'system'" json:"category"` // 'system', 'account' or 'marketing'
+	Severity    string           `gorm:"default:'info'" json:"severity"`
+	ActionURL   string           `json:"action_url,omitempty"`
+	Segment     BroadcastSegment `gorm:"type:jsonb" json:"segment"`
+	Channels    StringList       `gorm:"type:jsonb" json:"channels"` // Empty means every channel
+	Status      string           `gorm:"index;default:'draft'" json:"status"`
+	ScheduledAt *time.Time       `gorm:"index" json:"scheduled_at,omitempty"`
+	StartedAt   *time.Time       `json:"started_at,omitempty"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Recipients  int              `json:"recipients"` // Users matched when sending started
+	Sent        int              `json:"sent"`       // Users processed so far
+	Cursor      uint             `json:"-"`          // Last user ID processed, so an interrupted send resumes
+	LockedBy    string           `json:"-"`          // Sender currently holding the lease
+	LockedUntil *time.Time       `json:"-"`          // Lease expiry; another instance may take over after it
+	CreatedByID uint             `json:"created_by_id"`
+	CreatedBy   User             `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// BroadcastRecipient records that a broadcast was handed to a user, so a send
+// that resumes or overlaps with another instance never notifies anyone twice.
+type BroadcastRecipient struct {
+	BroadcastID uint `gorm:"primaryKey;autoIncrement:false"`
+	UserID      uint `gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt   time.Time
+}
+
+const (
+	BroadcastStatusDraft     = "draft"
+	BroadcastStatusScheduled = "scheduled"
+	BroadcastStatusSending   = "sending"
+	BroadcastStatusSent      = "sent"
+	BroadcastStatusCancelled = "cancelled"
+)
+
+// BroadcastSegment selects users. Every set field must match; empty fields match everyone.
+type BroadcastSegment struct {
+	Roles            []string   `json:"roles,omitempty"`
+	Badges           []string   `json:"badges,omitempty"`
+	Districts        []string   `json:"districts,omitempty"` // Users with an active listing in one of these districts
+	SignedUpAfter    *time.Time `json:"signed_up_after,omitempty"`
+	SignedUpBefore   *time.Time `json:"signed_up_before,omitempty"`
+	ActiveWithinDays int        `json:"active_within_days,omitempty"` // Listed, posted a requirement, inquired or chatted recently
+	InactiveForDays  int        `json:"inactive_for_days,omitempty"`  // None of the above for this long
+}
+
+func (s BroadcastSegment) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func (s *BroadcastSegment) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+// StringList is a list of strings stored in a jsonb column.
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(l)
+	return string(b), err
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	return scanJSON(value, l)
+}
+
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", value, dest)
+	}
+}
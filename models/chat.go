@@ -1,44 +1,158 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type ChatThread struct {
-	ID             uint          `gorm:"primaryKey" json:"id"`
-	PropertyID     *uint         `json:"property_id,omitempty"`
-	Property       *Property     `gorm:"foreignKey:PropertyID" json:"property,omitempty"`
-	Participant1ID uint          `json:"participant1_id"`
-	Participant1   User          `gorm:"foreignKey:Participant1ID" json:"participant1"`
-	Participant2ID uint          `json:"participant2_id"`
-	Participant2   User          `gorm:"foreignKey:Participant2ID" json:"participant2"`
-	LastMessage    string        `json:"last_message"`
-	UpdatedAt      time.Time     `json:"updated_at"`
-	Messages       []ChatMessage `gorm:"foreignKey:ThreadID" json:"messages"`
-	UnreadCount    int           `gorm:"-" json:"unread_count"` // Computed field
-	IsTyping1      bool          `gorm:"default:false" json:"is_typing1"` // Participant1 typing status
-	IsTyping2      bool          `gorm:"default:false" json:"is_typing2"` // Participant2 typing status
-	LastActivity   time.Time     `json:"last_activity"` // Last activity timestamp
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	PropertyID   *uint             `json:"property_id,omitempty"`
+	Property     *Property         `gorm:"foreignKey:PropertyID" json:"property,omitempty"`
+	Title        string            `json:"title"`                             // Optional name for group threads
+	InquiryID    *uint             `gorm:"index" json:"inquiry_id,omitempty"` // Set when the thread backs an inquiry
+	IsGroup      bool              `gorm:"default:false" json:"is_group"`     // False for one-to-one threads
+	CreatedByID  uint              `json:"created_by_id"`
+	Participants []ChatParticipant `gorm:"foreignKey:ThreadID" json:"participants"`
+	LastMessage  string            `json:"last_message"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	Messages     []ChatMessage     `gorm:"foreignKey:ThreadID" json:"messages"`
+	UnreadCount  int               `gorm:"-" json:"unread_count"` // Computed field
+	IsArchived   bool              `gorm:"-" json:"is_archived"`  // Caller's own thread state, computed per request
+	IsMuted      bool              `gorm:"-" json:"is_muted"`
+	IsPinned     bool              `gorm:"-" json:"is_pinned"`
+	LastActivity time.Time         `json:"last_activity"` // Last activity timestamp
+}
+
+// ChatParticipant is a user's membership of a thread along with their own
+// read state and private thread settings. Typing indicators are ephemeral and
+// live in the ws hub.
+type ChatParticipant struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	ThreadID          uint       `gorm:"uniqueIndex:idx_chat_participant" json:"thread_id"`
+	UserID            uint       `gorm:"uniqueIndex:idx_chat_participant;index" json:"user_id"`
+	User              User       `gorm:"foreignKey:UserID" json:"user"`
+	Role              string     `gorm:"default:'seeker'" json:"role"`          // 'owner', 'broker', 'seeker' or 'observer' (admin)
+	LastReadMessageID uint       `gorm:"default:0" json:"last_read_message_id"` // Messages above this ID are unread
+	JoinedAt          time.Time  `json:"joined_at"`
+	ContactSharedAt   *time.Time `json:"contact_shared_at,omitempty"` // Set once the user chose to reveal their phone
+	// Private per-user settings, never exposed to other participants
+	IsArchived     bool       `gorm:"default:false" json:"-"`
+	IsMuted        bool       `gorm:"default:false" json:"-"`
+	MutedUntil     *time.Time `json:"-"` // Nil mutes indefinitely
+	IsPinned       bool       `gorm:"default:false" json:"-"`
+	PinnedAt       *time.Time `json:"-"`
+	IsDeletedForMe bool       `gorm:"default:false" json:"-"`
+	ClearedUpToID  uint       `gorm:"default:0" json:"-"` // Messages up to this ID are hidden after delete-for-me
+}
+
+// MarshalJSON shows other participants only the public profile of the user.
+func (p ChatParticipant) MarshalJSON() ([]byte, error) {
+	type participant ChatParticipant
+	return json.Marshal(struct {
+		participant
+		User ChatProfile `json:"user"`
+	}{participant(p), p.User.ChatProfile(p.ContactSharedAt != nil)})
+}
+
+// MutedAt reports whether notifications for the thread are silenced at t.
+func (p ChatParticipant) MutedAt(t time.Time) bool {
+	return p.IsMuted && (p.MutedUntil == nil || t.Before(*p.MutedUntil))
 }
 
+const (
+	ParticipantRoleOwner    = "owner"
+	ParticipantRoleBroker   = "broker"
+	ParticipantRoleSeeker   = "seeker"
+	ParticipantRoleObserver = "observer" // Admin observer; can read but not post
+)
+
 type ChatMessage struct {
-	ID          uint           `gorm:"primaryKey" json:"id"`
-	ThreadID    uint           `json:"thread_id"`
-	SenderID    uint           `json:"sender_id"`
-	Sender      User           `gorm:"foreignKey:SenderID" json:"sender"`
-	Content     string         `gorm:"type:text" json:"content"`
-	Status      MessageStatus  `gorm:"default:'sent'" json:"status"` // Message status: sent, delivered, read
-	IsEdited    bool           `gorm:"default:false" json:"is_edited"`
-	EditedAt    *time.Time     `json:"edited_at,omitempty"`
-	IsDeleted   bool           `gorm:"default:false" json:"is_deleted"`
-	DeletedAt   *time.Time     `json:"deleted_at,omitempty"`
-	CreatedAt   time.Time      `json:"created_at"`
-	DeliveredAt *time.Time     `json:"delivered_at,omitempty"`
-	ReadAt      *time.Time     `json:"read_at,omitempty"`
-	ReplyToID   *uint          `json:"reply_to_id,omitempty"`
-	ReplyTo     *ChatMessage   `gorm:"foreignKey:ReplyToID" json:"reply_to,omitempty"`
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	ThreadID    uint             `json:"thread_id"`
+	SenderID    uint             `json:"sender_id"`
+	Sender      User             `gorm:"foreignKey:SenderID" json:"sender"`
+	Type        string           `gorm:"default:'text'" json:"type"` // 'text' or 'system'
+	Content     string           `gorm:"type:text" json:"content"`
+	Status      MessageStatus    `gorm:"default:'sent'" json:"status"` // Message status: sent, delivered, read
+	IsEdited    bool             `gorm:"default:false" json:"is_edited"`
+	EditedAt    *time.Time       `json:"edited_at,omitempty"`
+	IsDeleted   bool             `gorm:"default:false" json:"is_deleted"`
+	DeletedAt   *time.Time       `json:"deleted_at,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `gorm:"index" json:"updated_at"` // Bumped when content or deletion changes, not by receipts; drives delta sync
+	DeliveredAt *time.Time       `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time       `json:"read_at,omitempty"`
+	ReplyToID   *uint            `json:"reply_to_id,omitempty"`
+	ReplyTo     *ChatMessage     `gorm:"foreignKey:ReplyToID" json:"reply_to,omitempty"`
+	Attachments []ChatAttachment `gorm:"foreignKey:MessageID" json:"attachments"`
+}
+
+// MarshalJSON shows only the sender's public profile.
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	type message ChatMessage
+	return json.Marshal(struct {
+		message
+		Sender ChatProfile `json:"sender"`
+	}{message(m), m.Sender.ChatProfile(false)})
+}
+
+// ChatProfile is what chat and inquiry counterparts see of a user.
+type ChatProfile struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"` // Display name; an alias for anonymized users where the caller set one
+	Role  string `json:"role"`
+	Badge string `json:"badge"`
+	Phone string `json:"phone,omitempty"` // Only once the user chose to share it
+}
+
+// ChatAttachment is a file shared in a chat thread. Files are stored outside the
+// public uploads directory and only served to thread participants.
+type ChatAttachment struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	ThreadID        uint      `gorm:"index" json:"thread_id"`
+	MessageID       *uint     `gorm:"index" json:"message_id,omitempty"` // Nil until attached to a sent message
+	UploaderID      uint      `json:"uploader_id"`
+	Kind            string    `json:"kind"` // 'image', 'document' or 'voice'
+	FileName        string    `json:"file_name"`
+	MimeType        string    `json:"mime_type"`
+	Size            int64     `json:"size"`
+	DurationSeconds int       `json:"duration_seconds,omitempty"` // Voice notes only
+	StoragePath     string    `json:"-"`
+	ThumbnailPath   string    `json:"-"`
+	URL             string    `gorm:"-" json:"url"`
+	ThumbnailURL    string    `gorm:"-" json:"thumbnail_url,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
+const (
+	AttachmentKindImage    = "image"
+	AttachmentKindDocument = "document"
+	AttachmentKindVoice    = "voice"
+)
+
+// FillURLs sets the download URLs served by the chat attachment routes.
+func (a *ChatAttachment) FillURLs() {
+	a.URL = fmt.Sprintf("/api/chat/attachments/%d", a.ID)
+	a.ThumbnailURL = ""
+	if a.ThumbnailPath != "" {
+		a.ThumbnailURL = fmt.Sprintf("/api/chat/attachments/%d/thumbnail", a.ID)
+	}
+}
+
+func (a *ChatAttachment) AfterFind(tx *gorm.DB) error {
+	a.FillURLs()
+	return nil
+}
+
+const (
+	MessageTypeText   = "text"
+	MessageTypeSystem = "system" // Generated for events such as participants joining or leaving
+)
+
 type MessageStatus string
 
 const (
@@ -48,13 +162,13 @@ const (
 )
 
 type TypingStatus struct {
-	UserID   uint  `json:"user_id"`
-	ThreadID uint  `json:"thread_id"`
-	IsTyping bool  `json:"is_typing"`
+	UserID   uint `json:"user_id"`
+	ThreadID uint `json:"thread_id"`
+	IsTyping bool `json:"is_typing"`
 }
 
 type MessageSearchResult struct {
 	Message   ChatMessage `json:"message"`
 	Thread    ChatThread  `json:"thread"`
-	Highlight string      `json:"highlight"`
+	Highlight string      `json:"highlight"` // HTML-escaped snippet with matches wrapped in <mark>
 }
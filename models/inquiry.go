@@ -1,29 +1,47 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type Inquiry struct {
-	ID             uint             `gorm:"primaryKey" json:"id"`
-	PropertyID     uint             `json:"property_id"`
-	Property       Property         `gorm:"foreignKey:PropertyID" json:"property"`
-	SeekerID       uint             `json:"seeker_id"`
-	Seeker         User             `gorm:"foreignKey:SeekerID" json:"seeker"`
-	OwnerID        uint             `json:"owner_id"`
-	Owner          User             `gorm:"foreignKey:OwnerID" json:"owner"`
-	InitialMessage string           `gorm:"type:text" json:"initial_message"`
-	ExpectedDate   string           `json:"expected_date"`
-	Budget         float64          `json:"budget"`
-	Status         string           `gorm:"default:'Open'" json:"status"` // 'Open', 'Closed', 'Accepted'
-	Messages       []InquiryMessage `gorm:"foreignKey:InquiryID" json:"messages"`
-	CreatedAt      time.Time        `json:"created_at"`
-	UpdatedAt      time.Time        `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt   `gorm:"index" json:"-"`
+	ID                  uint           `gorm:"primaryKey" json:"id"`
+	PropertyID          uint           `json:"property_id"`
+	Property            Property       `gorm:"foreignKey:PropertyID" json:"property"`
+	SeekerID            uint           `json:"seeker_id"`
+	Seeker              User           `gorm:"foreignKey:SeekerID" json:"seeker"`
+	OwnerID             uint           `json:"owner_id"`
+	Owner               User           `gorm:"foreignKey:OwnerID" json:"owner"`
+	InitialMessage      string         `gorm:"type:text" json:"initial_message"`
+	ExpectedDate        string         `json:"expected_date"`
+	Budget              float64        `json:"budget"`
+	Status              string         `gorm:"default:'Open'" json:"status"` // 'Open', 'Closed', 'Accepted'
+	SeekerSharedContact bool           `gorm:"default:false" json:"seeker_shared_contact"`
+	OwnerSharedContact  bool           `gorm:"default:false" json:"owner_shared_contact"`
+	ChatThreadID        *uint          `gorm:"index" json:"chat_thread_id"` // Conversation for this inquiry
+	ChatThread          *ChatThread    `gorm:"foreignKey:ChatThreadID" json:"chat_thread,omitempty"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// MarshalJSON shows only the public profiles of the seeker and owner, with a
+// phone number once that party shared it.
+func (i Inquiry) MarshalJSON() ([]byte, error) {
+	type inquiry Inquiry
+	return json.Marshal(struct {
+		inquiry
+		Seeker ChatProfile `json:"seeker"`
+		Owner  ChatProfile `json:"owner"`
+	}{inquiry(i), i.Seeker.ChatProfile(i.SeekerSharedContact), i.Owner.ChatProfile(i.OwnerSharedContact)})
+}
+
+// InquiryMessage is the legacy message store for inquiries. Inquiry messages are
+// now chat messages in the inquiry's ChatThread; these rows are only read by the
+// migration that copies them across.
 type InquiryMessage struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	InquiryID uint      `json:"inquiry_id"`
@@ -1,14 +1,86 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
 type Notification struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `json:"user_id"`
-	Content   string    `json:"content"`
-	Type      string    `json:"type"` // 'inquiry', 'payment', 'system'
-	IsRead    bool      `gorm:"default:false" json:"is_read"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"index" json:"user_id"`
+	Content     string    `json:"content"`
+	Type        string    `json:"type"`                                   // Legacy alias of Category for older clients
+	Category    string    `gorm:"index;default:'system'" json:"category"` // One of the NotificationCategory* values
+	Severity    string    `gorm:"default:'info'" json:"severity"`         // 'info', 'success', 'warning' or 'critical'
+	EntityType  string    `json:"entity_type,omitempty"`                  // What the notification is about, e.g. 'property'
+	EntityID    *uint     `json:"entity_id,omitempty"`                    // ID of that entity
+	ActionURL   string    `json:"action_url,omitempty"`                   // Frontend path to open on click
+	Data        JSONMap   `gorm:"type:jsonb" json:"data,omitempty"`       // Extra structured details for rendering
+	BroadcastID *uint     `gorm:"index" json:"broadcast_id,omitempty"`    // Set when sent as part of an admin broadcast
+	IsRead      bool      `gorm:"default:false;index" json:"is_read"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Channels restricts delivery to these channels; empty means all. Not stored.
+	Channels []string `gorm:"-" json:"-"`
+}
+
+const (
+	NotificationCategoryInquiry     = "inquiry"
+	NotificationCategoryChat        = "chat"
+	NotificationCategoryListing     = "listing"
+	NotificationCategoryRequirement = "requirement"
+	NotificationCategoryPayment     = "payment"
+	NotificationCategoryModeration  = "moderation"
+	NotificationCategoryAccount     = "account"
+	NotificationCategoryMarketing   = "marketing" // Promotions and newsletters
+	NotificationCategorySystem      = "system"
+)
+
+var NotificationCategories = []string{
+	NotificationCategoryInquiry,
+	NotificationCategoryChat,
+	NotificationCategoryListing,
+	NotificationCategoryRequirement,
+	NotificationCategoryPayment,
+	NotificationCategoryModeration,
+	NotificationCategoryAccount,
+	NotificationCategoryMarketing,
+	NotificationCategorySystem,
+}
+
+const (
+	SeverityInfo     = "info"
+	SeveritySuccess  = "success"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// JSONMap is a free-form JSON object stored in a jsonb column.
+type JSONMap map[string]interface{}
+
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func (m *JSONMap) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONMap", value)
+	}
+	return json.Unmarshal(b, m)
 }
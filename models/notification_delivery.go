@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// NotificationDelivery logs one attempt to deliver a notification over a channel.
+type NotificationDelivery struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	NotificationID   *uint     `gorm:"index" json:"notification_id,omitempty"` // Nil when the user has in-app notifications off
+	UserID           uint      `gorm:"index" json:"user_id"`
+	BroadcastID      *uint     `gorm:"index" json:"broadcast_id,omitempty"`
+	NotificationType string    `json:"notification_type"`
+	Channel          string    `gorm:"index" json:"channel"` // 'in_app', 'email', 'sms' or 'web_push'
+	Attempt          int       `json:"attempt"`
+	Status           string    `json:"status"` // 'sent' or 'failed'
+	Error            string    `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+const (
+	DeliveryStatusSent   = "sent"
+	DeliveryStatusFailed = "failed"
+)
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// NotificationPreference is how a user wants one category of notification on
+// one channel. Missing rows fall back to the defaults in package notify.
+type NotificationPreference struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_notification_pref" json:"user_id"`
+	Category  string    `gorm:"uniqueIndex:idx_notification_pref" json:"category"`
+	Channel   string    `gorm:"uniqueIndex:idx_notification_pref" json:"channel"` // 'in_app', 'email' or 'sms'
+	Frequency string    `gorm:"not null" json:"frequency"`                        // One of the Frequency* values
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const (
+	FrequencyInstant = "instant"
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyOff     = "off"
+)
+
+// NotificationDigestItem is a notification held back for a user's daily or
+// weekly digest on a channel.
+type NotificationDigestItem struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"index:idx_digest_pending" json:"user_id"`
+	Channel        string     `json:"channel"`
+	Frequency      string     `gorm:"index:idx_digest_pending" json:"frequency"`
+	NotificationID *uint      `json:"notification_id,omitempty"`
+	Category       string     `json:"category"`
+	Content        string     `gorm:"type:text" json:"content"`
+	ActionURL      string     `json:"action_url,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	SentAt         *time.Time `gorm:"index:idx_digest_pending" json:"sent_at,omitempty"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Permission is a single capability checked by RequirePermission, e.g. "listings.verify".
+type Permission struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Key         string `gorm:"uniqueIndex;not null" json:"key"`
+	Description string `json:"description"`
+}
+
+// Role is a named set of permissions. A user has the permissions of the role
+// named by User.Role plus those of any roles granted to them.
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"uniqueIndex;not null" json:"name"`
+	Description string       `json:"description"`
+	IsSystem    bool         `gorm:"default:false" json:"is_system"` // Built-in roles cannot be deleted
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// UserRoleGrant gives a user a role on top of their account role, e.g. a
+// property owner who also moderates listings.
+type UserRoleGrant struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_user_role_grant" json:"user_id"`
+	RoleID      uint      `gorm:"uniqueIndex:idx_user_role_grant" json:"role_id"`
+	Role        Role      `json:"role"`
+	GrantedByID uint      `json:"granted_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
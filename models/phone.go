@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// DefaultCountryCode is assumed for numbers written without one.
+const DefaultCountryCode = "91"
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// NormalizePhone converts a phone number as users type it ("094252 12345",
+// "+91-94252-12345", "0091 9425212345") to E.164 ("+919425212345").
+// Numbers without a country code are taken to be Indian.
+func NormalizePhone(raw string) (string, error) {
+	var digits strings.Builder
+	plus := false
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+			plus = true
+		case r == ' ' || r == '-' || r == '(' || r == ')' || r == '.':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case plus:
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case len(number) == 10:
+		number = DefaultCountryCode + number
+	case len(number) == 11 && number[0] == '0':
+		number = DefaultCountryCode + number[1:]
+	case len(number) == 12 && strings.HasPrefix(number, DefaultCountryCode):
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	// Indian mobile numbers are ten digits starting with 6-9
+	if strings.HasPrefix(number, "91") && (len(number) != 12 || number[2] < '6') {
+		return "", ErrInvalidPhone
+	}
+	return "+" + number, nil
+}
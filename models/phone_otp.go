@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// PhoneOTP is a one-time code texted to a phone number, for signing in or for
+// confirming the number on an account. Only an HMAC of the code is stored.
+type PhoneOTP struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Phone      string     `gorm:"index;not null" json:"phone"` // E.164
+	UserID     *uint      `gorm:"index" json:"user_id,omitempty"`
+	Purpose    string     `gorm:"not null" json:"purpose"` // 'login' or 'verify_phone'
+	CodeHash   string     `gorm:"not null" json:"-"`
+	Attempts   int        `gorm:"default:0" json:"attempts"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+const (
+	OTPPurposeLogin       = "login"
+	OTPPurposeVerifyPhone = "verify_phone"
+)
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PushSubscription is one browser or device registered for Web Push.
+type PushSubscription struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index" json:"user_id"`
+	Endpoint   string     `gorm:"type:text;uniqueIndex;not null" json:"endpoint"`
+	P256dh     string     `gorm:"not null" json:"-"` // Client public key, base64url
+	Auth       string     `gorm:"not null" json:"-"` // Client auth secret, base64url
+	UserAgent  string     `json:"user_agent"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // From the browser's expirationTime, if any
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
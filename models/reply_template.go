@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// ReplyTemplate is a saved quick reply. Bodies may contain placeholders such as
+// {property_title} that are filled in for a specific thread or inquiry.
+type ReplyTemplate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Title     string    `gorm:"not null" json:"title"`
+	Body      string    `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SystemReplyTemplate is a default quick reply offered to every user. Its body
+// is stored in SiteConfig under Key so admins can edit it through the CMS.
+type SystemReplyTemplate struct {
+	Key   string `json:"key"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+var DefaultReplyTemplates = []SystemReplyTemplate{
+	{
+		Key:   "reply_template_availability",
+		Title: "Still available",
+		Body:  "Yes, {property_title} in {location} is still available. Let me know if you would like to see it.",
+	},
+	{
+		Key:   "reply_template_price",
+		Title: "Final price",
+		Body:  "The asking price for {property_title} is {price}. There is a little room for negotiation for serious buyers.",
+	},
+	{
+		Key:   "reply_template_site_visit",
+		Title: "Site visit",
+		Body:  "Happy to arrange a site visit to {property_title} in {location}. You can reach me on {my_phone} to fix a time.",
+	},
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Report is a user-submitted complaint that feeds the admin moderation queue.
+type Report struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ReporterID     *uint      `json:"reporter_id"` // Nil for automatic system reports
+	Reporter       *User      `gorm:"foreignKey:ReporterID" json:"reporter,omitempty"`
+	TargetType     string     `gorm:"not null;index:idx_report_target" json:"target_type"` // 'user', 'message', 'inquiry', 'inquiry_message', 'property' or 'requirement'
+	TargetID       uint       `gorm:"not null;index:idx_report_target" json:"target_id"`
+	Reason         string     `gorm:"not null" json:"reason"` // 'spam', 'harassment', 'fraud', 'inappropriate', 'other' or 'contact_sharing'
+	Details        string     `gorm:"type:text" json:"details"`
+	Status         string     `gorm:"default:'pending';index" json:"status"` // 'pending', 'resolved' or 'dismissed'
+	ResolvedByID   *uint      `json:"resolved_by_id,omitempty"`
+	ResolutionNote string     `gorm:"type:text" json:"resolution_note"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+const (
+	ReportStatusPending   = "pending"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// ReportReasonContactSharing marks reports filed automatically by the chat contact filter.
+const ReportReasonContactSharing = "contact_sharing"
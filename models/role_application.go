@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// RoleApplication is a request to become a developer or broker. Admins review
+// it; approval sets the applicant's role, badge and company name.
+type RoleApplication struct {
+	ID            uint                  `gorm:"primaryKey" json:"id"`
+	UserID        uint                  `gorm:"not null;index" json:"user_id"`
+	User          *User                 `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Role          string                `gorm:"not null" json:"role"` // 'developer' or 'broker'
+	CompanyName   string                `gorm:"not null" json:"company_name"`
+	LicenseNumber string                `gorm:"not null" json:"license_number"`        // RERA registration or broker licence number
+	Status        string                `gorm:"default:'pending';index" json:"status"` // 'pending', 'approved', 'rejected' or 'withdrawn'
+	ReviewNote    string                `gorm:"type:text" json:"review_note"`          // Shown to the applicant; required on rejection
+	ReviewedByID  *uint                 `json:"reviewed_by_id,omitempty"`
+	ReviewedAt    *time.Time            `json:"reviewed_at,omitempty"`
+	Documents     []ApplicationDocument `gorm:"foreignKey:ApplicationID" json:"documents"`
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+}
+
+const (
+	ApplicationRoleDeveloper = "developer"
+	ApplicationRoleBroker    = "broker"
+)
+
+const (
+	ApplicationStatusPending   = "pending"
+	ApplicationStatusApproved  = "approved"
+	ApplicationStatusRejected  = "rejected"
+	ApplicationStatusWithdrawn = "withdrawn"
+)
+
+// ApplicationDocument is a supporting file for a RoleApplication, e.g. a RERA
+// certificate. Files are stored outside the public uploads directory.
+type ApplicationDocument struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ApplicationID uint      `gorm:"index" json:"application_id"`
+	FileName      string    `json:"file_name"`
+	MimeType      string    `json:"mime_type"`
+	Size          int64     `json:"size"`
+	StoragePath   string    `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Session is one signed-in device. Access tokens carry its ID and stop working
+// once it is revoked; the refresh token is rotated on every use.
+type Session struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserID            uint       `gorm:"index" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex;not null" json:"-"` // SHA-256 of the current refresh token
+	PreviousTokenHash string     `gorm:"index" json:"-"`                // Last rotated-out token, to detect reuse
+	UserAgent         string     `json:"user_agent"`
+	IPAddress         string     `json:"ip_address"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
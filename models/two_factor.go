@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// TwoFactor holds a user's TOTP authenticator. It exists from the start of
+// enrollment; EnabledAt is set once the user has proved the app works.
+type TwoFactor struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"uniqueIndex" json:"user_id"`
+	Secret       string     `gorm:"not null" json:"-"` // Encrypted base32 TOTP secret
+	LastUsedStep int64      `json:"-"`                 // Time step of the last accepted code, so it cannot be replayed
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// RecoveryCode is a single-use backup for a lost authenticator.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TwoFactorChallenge is issued after a correct first factor and exchanged for
+// a session once the second factor checks out.
+type TwoFactorChallenge struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index" json:"user_id"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Attempts   int        `gorm:"default:0" json:"attempts"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
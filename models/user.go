@@ -10,16 +10,34 @@ type User struct {
 	ID                 uint           `gorm:"primaryKey" json:"id"`
 	Name               string         `gorm:"not null" json:"name"`
 	Email              string         `gorm:"uniqueIndex;not null" json:"email"`
+	EmailVerified      bool           `gorm:"default:false" json:"email_verified"`
+	EmailVerifiedAt    *time.Time     `json:"email_verified_at,omitempty"`
 	Password           string         `gorm:"not null" json:"-"`
-	Phone              string         `json:"phone"`
-	Role               string         `gorm:"default:'seeker'" json:"role"`                  // 'seeker' or 'owner' or 'admin' or 'developer'
+	GoogleSubject      *string        `gorm:"uniqueIndex" json:"-"` // Google account ID ("sub") for Google sign-in
+	Phone              string         `json:"phone"`                // E.164, e.g. +919425212345
+	PhoneVerified      bool           `gorm:"default:false" json:"phone_verified"`
+	PhoneVerifiedAt    *time.Time     `json:"phone_verified_at,omitempty"`
+	TwoFactorEnabled   bool           `gorm:"default:false" json:"two_factor_enabled"`
+	SuspendedAt        *time.Time     `gorm:"index" json:"suspended_at,omitempty"`           // Banned by an admin; blocks every sign-in method
+	Role               string         `gorm:"default:'seeker'" json:"role"`                  // 'seeker', 'owner', 'developer', 'broker' or a staff role
 	CompanyName        string         `json:"company_name"`                                  // Optional for developers
 	PublicPreference   string         `gorm:"default:'Anonymized'" json:"public_preference"` // 'Anonymized' or 'Full'
 	Badge              string         `gorm:"default:'User'" json:"badge"`                   // 'Verified Broker', 'User', 'Verified'
 	ContactPreference  string         `gorm:"default:'In-app'" json:"contact_preference"`    // 'In-app' or 'Email' or 'Phone'
 	EmailNotifications bool           `gorm:"default:true" json:"email_notifications"`
 	InAppNotifications bool           `gorm:"default:true" json:"in_app_notifications"`
+	SMSNotifications   bool           `gorm:"default:false" json:"sms_notifications"` // Texts go to Phone
 	CreatedAt          time.Time      `json:"created_at"`
 	UpdatedAt          time.Time      `json:"updated_at"`
 	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
 }
+
+// ChatProfile is the public view of u. The phone is included only when u agreed
+// to share it and has verified it.
+func (u User) ChatProfile(shareContact bool) ChatProfile {
+	profile := ChatProfile{ID: u.ID, Name: u.Name, Role: u.Role, Badge: u.Badge}
+	if shareContact && u.PhoneVerified {
+		profile.Phone = u.Phone
+	}
+	return profile
+}
@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Users handled per batch when sending a broadcast. BROADCAST_BATCH_SIZE overrides it.
+var broadcastBatchSize = 200
+
+// Pause between batches so a large broadcast does not crowd out other traffic.
+var broadcastBatchPause = time.Second
+
+// How long a sender holds a broadcast between renewals. An instance that dies
+// mid-send loses the lease after this and the scheduler elsewhere resumes it.
+const broadcastLease = 2 * time.Minute
+
+var (
+	runningMu sync.Mutex
+	running   = make(map[uint]bool)
+)
+
+// activityQuery is a user_id list of everyone who did something since the given time.
+const activityQuery = `SELECT owner_id FROM properties WHERE created_at >= @since
+	UNION SELECT user_id FROM requirements WHERE created_at >= @since
+	UNION SELECT seeker_id FROM inquiries WHERE created_at >= @since
+	UNION SELECT sender_id FROM chat_messages WHERE created_at >= @since`
+
+// SegmentQuery returns a query over users matching the segment.
+func SegmentQuery(segment models.BroadcastSegment) *gorm.DB {
+	query := config.DB.Model(&models.User{})
+
+	if len(segment.Roles) > 0 {
+		query = query.Where("role IN ?", segment.Roles)
+	}
+	if len(segment.Badges) > 0 {
+		query = query.Where("badge IN ?", segment.Badges)
+	}
+	if len(segment.Districts) > 0 {
+		query = query.Where("id IN (?)", config.DB.Model(&models.Property{}).
+			Select("owner_id").Where("is_active = ? AND district IN ?", true, segment.Districts))
+	}
+	if segment.SignedUpAfter != nil {
+		query = query.Where("created_at >= ?", *segment.SignedUpAfter)
+	}
+	if segment.SignedUpBefore != nil {
+		query = query.Where("created_at < ?", *segment.SignedUpBefore)
+	}
+	if segment.ActiveWithinDays > 0 {
+		since := time.Now().AddDate(0, 0, -segment.ActiveWithinDays)
+		query = query.Where("id IN ("+activityQuery+")", map[string]interface{}{"since": since})
+	}
+	if segment.InactiveForDays > 0 {
+		since := time.Now().AddDate(0, 0, -segment.InactiveForDays)
+		query = query.Where("id NOT IN ("+activityQuery+")", map[string]interface{}{"since": since})
+	}
+
+	return query
+}
+
+// RunBroadcast sends a broadcast to its segment in batches, continuing from
+// where an earlier run stopped. It returns immediately if the broadcast is
+// already being sent, by this process or by another instance holding its lease.
+func RunBroadcast(broadcastID uint) {
+	runningMu.Lock()
+	if running[broadcastID] {
+		runningMu.Unlock()
+		return
+	}
+	running[broadcastID] = true
+	runningMu.Unlock()
+
+	defer func() {
+		runningMu.Lock()
+		delete(running, broadcastID)
+		runningMu.Unlock()
+	}()
+
+	owner, ok := claimBroadcast(broadcastID)
+	if !ok {
+		return
+	}
+	defer config.DB.Model(&models.Broadcast{}).Where("id = ? AND locked_by = ?", broadcastID, owner).
+		Updates(map[string]interface{}{"locked_by": "", "locked_until": nil})
+
+	var broadcast models.Broadcast
+	if err := config.DB.First(&broadcast, broadcastID).Error; err != nil {
+		log.Printf("Broadcast %d not found: %v", broadcastID, err)
+		return
+	}
+	if broadcast.StartedAt == nil {
+		var recipients int64
+		SegmentQuery(broadcast.Segment).Count(&recipients)
+		now := time.Now()
+		broadcast.StartedAt = &now
+		broadcast.Recipients = int(recipients)
+		config.DB.Model(&broadcast).Updates(map[string]interface{}{"started_at": now, "recipients": recipients})
+	}
+
+	for {
+		// Renewing the lease also stops us if an admin cancelled or another
+		// instance took over after we stalled
+		renewed := config.DB.Model(&models.Broadcast{}).
+			Where("id = ? AND locked_by = ? AND status = ?", broadcast.ID, owner, models.BroadcastStatusSending).
+			Update("locked_until", time.Now().Add(broadcastLease))
+		if renewed.RowsAffected == 0 {
+			log.Printf("Broadcast %d stopped after %d users", broadcast.ID, broadcast.Sent)
+			return
+		}
+
+		var userIDs []uint
+		SegmentQuery(broadcast.Segment).Where("id > ?", broadcast.Cursor).
+			Order("id").Limit(broadcastBatchSize).Pluck("id", &userIDs)
+		if len(userIDs) == 0 {
+			break
+		}
+
+		for _, userID := range userIDs {
+			if !claimRecipient(broadcast.ID, userID) {
+				continue
+			}
+			if err := dispatcher.Send(broadcastNotification(broadcast, userID)); err != nil {
+				log.Printf("Broadcast %d: failed to notify user %d: %v", broadcast.ID, userID, err)
+			}
+		}
+
+		broadcast.Cursor = userIDs[len(userIDs)-1]
+		broadcast.Sent += len(userIDs)
+		config.DB.Model(&broadcast).Updates(map[string]interface{}{"cursor": broadcast.Cursor, "sent": broadcast.Sent})
+
+		time.Sleep(broadcastBatchPause)
+	}
+
+	config.DB.Model(&broadcast).Where("status = ?", models.BroadcastStatusSending).
+		Updates(map[string]interface{}{"status": models.BroadcastStatusSent, "completed_at": time.Now()})
+	log.Printf("Broadcast %d sent to %d users", broadcast.ID, broadcast.Sent)
+}
+
+// claimBroadcast takes the sending lease on a broadcast. It returns the
+// owner token to renew and release it with.
+func claimBroadcast(broadcastID uint) (string, bool) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", false
+	}
+	owner := hex.EncodeToString(b)
+
+	now := time.Now()
+	result := config.DB.Model(&models.Broadcast{}).
+		Where("id = ? AND status = ? AND (locked_until IS NULL OR locked_until < ?)", broadcastID, models.BroadcastStatusSending, now).
+		Updates(map[string]interface{}{"locked_by": owner, "locked_until": now.Add(broadcastLease)})
+	return owner, result.Error == nil && result.RowsAffected == 1
+}
+
+// claimRecipient records the user as handled before sending. It is false if
+// they already were, e.g. in a batch that was cut short by a crash.
+func claimRecipient(broadcastID, userID uint) bool {
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.BroadcastRecipient{BroadcastID: broadcastID, UserID: userID})
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func broadcastNotification(broadcast models.Broadcast, userID uint) models.Notification {
+	return models.Notification{
+		UserID:      userID,
+		Content:     broadcast.Content,
+		Category:    broadcast.Category,
+		Severity:    broadcast.Severity,
+		ActionURL:   broadcast.ActionURL,
+		BroadcastID: &broadcast.ID,
+		Channels:    broadcast.Channels,
+		Data:        models.JSONMap{"broadcast_id": broadcast.ID, "title": broadcast.Title},
+	}
+}
+
+// StartBroadcastScheduler starts scheduled broadcasts once they are due and
+// resumes any that were interrupted mid-send. It blocks, so run it in its own
+// goroutine.
+func StartBroadcastScheduler() {
+	if n, err := strconv.Atoi(os.Getenv("BROADCAST_BATCH_SIZE")); err == nil && n > 0 {
+		broadcastBatchSize = n
+	}
+
+	startDueBroadcasts()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		startDueBroadcasts()
+	}
+}
+
+func startDueBroadcasts() {
+	config.DB.Model(&models.Broadcast{}).
+		Where("status = ? AND scheduled_at <= ?", models.BroadcastStatusScheduled, time.Now()).
+		Update("status", models.BroadcastStatusSending)
+
+	var ids []uint
+	config.DB.Model(&models.Broadcast{}).Where("status = ?", models.BroadcastStatusSending).Pluck("id", &ids)
+	for _, id := range ids {
+		go RunBroadcast(id)
+	}
+}
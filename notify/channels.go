@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"realstate-backend/models"
+	"sync"
+	"time"
+)
+
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelSMS     = "sms"
+	ChannelWebPush = "web_push"
+)
+
+// Channel delivers a notification to a user outside the app. In-app delivery
+// is handled by the dispatcher itself, since it creates the stored row.
+type Channel interface {
+	Name() string
+	// Enabled reports whether this notification should go to the user over
+	// the channel, given their preferences and contact details.
+	Enabled(user models.User, notification models.Notification) bool
+	Deliver(user models.User, notification models.Notification) error
+}
+
+// EmailChannel sends notifications as email through a Mailer.
+type EmailChannel struct {
+	Mailer Mailer
+}
+
+func (e *EmailChannel) Name() string { return ChannelEmail }
+
+func (e *EmailChannel) Enabled(user models.User, notification models.Notification) bool {
+	return user.EmailNotifications && user.Email != ""
+}
+
+func (e *EmailChannel) Deliver(user models.User, notification models.Notification) error {
+	subject, body, err := renderEmail(user, notification)
+	if err != nil {
+		return err
+	}
+
+	return e.Mailer.SendMail(user.Email, subject, body)
+}
+
+// DeliverDigest sends a batch of held-back notifications as a single email.
+func (e *EmailChannel) DeliverDigest(user models.User, frequency string, items []models.NotificationDigestItem) error {
+	subject, body, err := renderDigest(user, frequency, items)
+	if err != nil {
+		return err
+	}
+	return e.Mailer.SendMail(user.Email, subject, body)
+}
+
+// SMSProvider is the gateway an SMSChannel sends texts through.
+type SMSProvider interface {
+	SendSMS(to, body string) error
+}
+
+func smsProviderFromEnv() SMSProvider {
+	switch os.Getenv("SMS_PROVIDER") {
+	case "fake":
+		return &FakeSMSProvider{}
+	case "http":
+		return &HTTPSMSProvider{
+			URL:    os.Getenv("SMS_API_URL"),
+			APIKey: os.Getenv("SMS_API_KEY"),
+			Sender: os.Getenv("SMS_SENDER"),
+		}
+	}
+	return nil
+}
+
+// SMSProviderFromEnv returns the gateway picked by SMS_PROVIDER (fake or http),
+// or nil when none is configured. The fake provider logs codes, so it is never
+// picked implicitly.
+func SMSProviderFromEnv() SMSProvider {
+	return smsProviderFromEnv()
+}
+
+// ErrSMSNotConfigured is returned for account texts when no gateway is set up.
+var ErrSMSNotConfigured = errors.New("text messages are not configured")
+
+var smsProvider SMSProvider
+
+// SetSMSProvider replaces the gateway used for account texts such as login codes.
+func SetSMSProvider(p SMSProvider) {
+	smsProvider = p
+}
+
+// SMSConfigured reports whether account texts can be sent.
+func SMSConfigured() bool {
+	return smsProvider != nil
+}
+
+// SendAccountSMS texts to regardless of notification preferences, for codes and
+// other messages the user asked for.
+func SendAccountSMS(to, body string) error {
+	if smsProvider == nil {
+		return ErrSMSNotConfigured
+	}
+	return smsProvider.SendSMS(to, body)
+}
+
+// SMSChannel texts notification categories that have an SMS template to the user's phone.
+type SMSChannel struct {
+	Provider SMSProvider
+}
+
+func (s *SMSChannel) Name() string { return ChannelSMS }
+
+// Enabled only for verified numbers, so texts never go to a number the user
+// typed in but does not own.
+func (s *SMSChannel) Enabled(user models.User, notification models.Notification) bool {
+	_, hasTemplate := smsTemplates[notification.Category]
+	return hasTemplate && user.SMSNotifications && user.Phone != "" && user.PhoneVerified
+}
+
+func (s *SMSChannel) Deliver(user models.User, notification models.Notification) error {
+	body, err := renderSMS(user, notification)
+	if err != nil {
+		return err
+	}
+	return s.Provider.SendSMS(user.Phone, body)
+}
+
+// FakeSMSProvider records texts in memory and logs them instead of sending.
+// Useful in development and for exercising the dispatcher without a gateway.
+type FakeSMSProvider struct {
+	mu   sync.Mutex
+	Sent []FakeSMS
+	// Fail, when set, is returned from SendSMS to simulate gateway errors.
+	Fail error
+}
+
+type FakeSMS struct {
+	To   string
+	Body string
+	At   time.Time
+}
+
+func (f *FakeSMSProvider) SendSMS(to, body string) error {
+	if f.Fail != nil {
+		return f.Fail
+	}
+	f.mu.Lock()
+	f.Sent = append(f.Sent, FakeSMS{To: to, Body: body, At: time.Now()})
+	f.mu.Unlock()
+	log.Printf("[SMS] to %s: %s", to, body)
+	return nil
+}
+
+// HTTPSMSProvider posts texts as JSON to a gateway's HTTP API.
+type HTTPSMSProvider struct {
+	URL    string
+	APIKey string
+	Sender string
+	Client *http.Client
+}
+
+func (h *HTTPSMSProvider) SendSMS(to, body string) error {
+	payload, err := json.Marshal(map[string]string{"to": to, "from": h.Sender, "body": body})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.APIKey)
+	}
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sms gateway returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"log"
+	"os"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"strconv"
+	"time"
+)
+
+// DigestChannel is a channel that can send several notifications as one message.
+type DigestChannel interface {
+	Channel
+	DeliverDigest(user models.User, frequency string, items []models.NotificationDigestItem) error
+}
+
+func queueDigest(user models.User, channel, frequency string, notification models.Notification) {
+	item := models.NotificationDigestItem{
+		UserID:    user.ID,
+		Channel:   channel,
+		Frequency: frequency,
+		Category:  notification.Category,
+		Content:   notification.Content,
+		ActionURL: notification.ActionURL,
+	}
+	if notification.ID != 0 {
+		item.NotificationID = &notification.ID
+	}
+	if err := config.DB.Create(&item).Error; err != nil {
+		log.Printf("Failed to queue %s digest item for user %d: %v", frequency, user.ID, err)
+	}
+}
+
+// RunDigests sends every pending digest item of the given frequency, one
+// message per user and channel, and returns how many digests went out.
+func (d *Dispatcher) RunDigests(frequency string) int {
+	var pending []struct {
+		UserID  uint
+		Channel string
+	}
+	config.DB.Model(&models.NotificationDigestItem{}).
+		Select("DISTINCT user_id, channel").
+		Where("frequency = ? AND sent_at IS NULL", frequency).
+		Scan(&pending)
+
+	sent := 0
+	for _, p := range pending {
+		var items []models.NotificationDigestItem
+		config.DB.Where("user_id = ? AND channel = ? AND frequency = ? AND sent_at IS NULL", p.UserID, p.Channel, frequency).
+			Order("created_at ASC").Find(&items)
+		if len(items) == 0 {
+			continue
+		}
+		ids := make([]uint, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+
+		var user models.User
+		userErr := config.DB.First(&user, p.UserID).Error
+		channel := d.digestChannel(p.Channel)
+		if channel == nil {
+			// Leave items queued until the channel is configured again
+			continue
+		}
+
+		// Drop the backlog for users who are gone or have since switched the channel off
+		if userErr == nil && channel.Enabled(user, models.Notification{Category: items[0].Category}) {
+			digestType := "digest_" + frequency
+			err := d.withRetry(func() error { return channel.DeliverDigest(user, frequency, items) }, func(attempt int, err error) {
+				logAttempt(user.ID, digestType, nil, p.Channel, attempt, err)
+			})
+			if err != nil {
+				log.Printf("Giving up on %s digest for user %d: %v", frequency, user.ID, err)
+				continue
+			}
+			sent++
+		}
+
+		config.DB.Model(&models.NotificationDigestItem{}).Where("id IN ?", ids).Update("sent_at", time.Now())
+	}
+	return sent
+}
+
+func (d *Dispatcher) digestChannel(name string) DigestChannel {
+	for _, channel := range d.Channels {
+		if dc, ok := channel.(DigestChannel); ok && channel.Name() == name {
+			return dc
+		}
+	}
+	return nil
+}
+
+// RunDigests sends pending digests of the given frequency with the configured dispatcher.
+func RunDigests(frequency string) int {
+	return dispatcher.RunDigests(frequency)
+}
+
+// StartDigestScheduler sends daily digests every day and weekly digests on
+// Mondays, at DIGEST_HOUR (server local time, default 8). It blocks, so run it
+// in its own goroutine.
+func StartDigestScheduler() {
+	hour := 8
+	if h, err := strconv.Atoi(os.Getenv("DIGEST_HOUR")); err == nil && h >= 0 && h < 24 {
+		hour = h
+	}
+
+	var lastRun string
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		today := now.Format("2006-01-02")
+		if now.Hour() != hour || lastRun == today {
+			continue
+		}
+		lastRun = today
+
+		log.Printf("Sent %d daily notification digests", RunDigests(models.FrequencyDaily))
+		if now.Weekday() == time.Monday {
+			log.Printf("Sent %d weekly notification digests", RunDigests(models.FrequencyWeekly))
+		}
+	}
+}
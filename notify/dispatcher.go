@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"log"
+	"os"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"sync"
+	"time"
+)
+
+// Dispatcher fans a notification out to the in-app inbox and to every external
+// channel the user has enabled, following their per-category preferences.
+// External deliveries run in the background and are retried with exponential
+// backoff; every attempt is logged. Digest-frequency items are queued for
+// RunDigests instead.
+type Dispatcher struct {
+	Channels    []Channel
+	MaxAttempts int
+	Backoff     time.Duration // Wait before the second attempt; doubles after each failure
+
+	inflight sync.WaitGroup
+}
+
+// DispatcherFromEnv builds a dispatcher from SMTP_*, SMS_* and VAPID_* settings. Channels
+// that are not configured are left out.
+func DispatcherFromEnv() *Dispatcher {
+	d := &Dispatcher{MaxAttempts: 3, Backoff: 2 * time.Second}
+
+	if mailer := smtpMailerFromEnv(); mailer != nil {
+		d.Channels = append(d.Channels, &EmailChannel{Mailer: mailer})
+	}
+
+	if provider := smsProviderFromEnv(); provider != nil {
+		d.Channels = append(d.Channels, &SMSChannel{Provider: provider})
+	}
+
+	if key := os.Getenv("VAPID_PRIVATE_KEY"); key != "" {
+		channel, err := NewWebPushChannel(key, envOr("VAPID_SUBJECT", "mailto:support@rjgproperty.com"))
+		if err != nil {
+			log.Printf("Web push disabled: %v", err)
+		} else {
+			d.Channels = append(d.Channels, channel)
+		}
+	}
+
+	return d
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// Send stores the in-app notification, pushes it to the user's open
+// connections and queues delivery on the user's other channels. The returned
+// error only reflects the in-app part.
+func (d *Dispatcher) Send(notification models.Notification) error {
+	var user models.User
+	if err := config.DB.First(&user, notification.UserID).Error; err != nil {
+		return err
+	}
+
+	normalize(&notification)
+
+	prefs := Preferences(user.ID)
+
+	var err error
+	if allowsChannel(notification, ChannelInApp) && user.InAppNotifications && frequencyIn(prefs, notification.Category, ChannelInApp) != models.FrequencyOff {
+		err = config.DB.Create(&notification).Error
+		logDelivery(notification, ChannelInApp, 1, err)
+		if err != nil {
+			log.Printf("Failed to create notification for user %d: %v", user.ID, err)
+		} else {
+			pushNotification(notification)
+		}
+	}
+
+	for _, channel := range d.Channels {
+		if !allowsChannel(notification, channel.Name()) || !channel.Enabled(user, notification) {
+			continue
+		}
+
+		switch frequency := frequencyIn(prefs, notification.Category, channel.Name()); frequency {
+		case models.FrequencyOff:
+			continue
+		case models.FrequencyDaily, models.FrequencyWeekly:
+			if _, ok := channel.(DigestChannel); ok {
+				queueDigest(user, channel.Name(), frequency, notification)
+				continue
+			}
+		}
+
+		d.inflight.Add(1)
+		go d.deliver(channel, user, notification)
+	}
+
+	return err
+}
+
+func allowsChannel(notification models.Notification, channel string) bool {
+	if len(notification.Channels) == 0 {
+		return true
+	}
+	for _, c := range notification.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Wait blocks until queued external deliveries have finished, including retries.
+func (d *Dispatcher) Wait() {
+	d.inflight.Wait()
+}
+
+func (d *Dispatcher) deliver(channel Channel, user models.User, notification models.Notification) {
+	defer d.inflight.Done()
+
+	err := d.withRetry(func() error { return channel.Deliver(user, notification) }, func(attempt int, err error) {
+		logDelivery(notification, channel.Name(), attempt, err)
+	})
+	if err != nil {
+		log.Printf("Giving up on %s notification for user %d after %d attempts", channel.Name(), user.ID, d.MaxAttempts)
+	}
+}
+
+// withRetry calls send up to MaxAttempts times, doubling the wait after each
+// failure, and reports every attempt to record.
+func (d *Dispatcher) withRetry(send func() error, record func(attempt int, err error)) error {
+	delay := d.Backoff
+	var err error
+	for attempt := 1; attempt <= max(d.MaxAttempts, 1); attempt++ {
+		err = send()
+		record(attempt, err)
+		if err == nil {
+			return nil
+		}
+		if attempt < d.MaxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+func logDelivery(notification models.Notification, channel string, attempt int, err error) {
+	entry := models.NotificationDelivery{
+		UserID:           notification.UserID,
+		BroadcastID:      notification.BroadcastID,
+		NotificationType: notification.Category,
+		Channel:          channel,
+		Attempt:          attempt,
+	}
+	if notification.ID != 0 {
+		entry.NotificationID = &notification.ID
+	}
+	saveAttempt(entry, err)
+}
+
+func logAttempt(userID uint, notificationType string, notificationID *uint, channel string, attempt int, err error) {
+	saveAttempt(models.NotificationDelivery{
+		UserID:           userID,
+		NotificationID:   notificationID,
+		NotificationType: notificationType,
+		Channel:          channel,
+		Attempt:          attempt,
+	}, err)
+}
+
+func saveAttempt(entry models.NotificationDelivery, err error) {
+	entry.Status = models.DeliveryStatusSent
+	if err != nil {
+		entry.Status = models.DeliveryStatusFailed
+		entry.Error = err.Error()
+	}
+	config.DB.Create(&entry)
+}
@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"errors"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChannel records deliveries and fails the first few of them.
+type fakeChannel struct {
+	name     string
+	disabled bool
+	failures int
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeChannel) Name() string { return f.name }
+
+func (f *fakeChannel) Enabled(user models.User, notification models.Notification) bool {
+	return !f.disabled
+}
+
+func (f *fakeChannel) Deliver(user models.User, notification models.Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("temporarily unavailable")
+	}
+	return nil
+}
+
+func (f *fakeChannel) deliveries() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func TestWithRetry(t *testing.T) {
+	d := &Dispatcher{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"first attempt succeeds", 0, 1, false},
+		{"succeeds on retry", 2, 3, false},
+		{"gives up", 5, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := &fakeChannel{failures: tt.failures}
+			var attempts []int
+			err := d.withRetry(func() error { return channel.Deliver(models.User{}, models.Notification{}) },
+				func(attempt int, err error) { attempts = append(attempts, attempt) })
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if channel.deliveries() != tt.wantCalls {
+				t.Errorf("delivered %d times, want %d", channel.deliveries(), tt.wantCalls)
+			}
+			for i, attempt := range attempts {
+				if attempt != i+1 {
+					t.Errorf("attempts recorded as %v", attempts)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAllowsChannel(t *testing.T) {
+	everywhere := models.Notification{}
+	emailOnly := models.Notification{Channels: models.StringList{ChannelEmail}}
+
+	if !allowsChannel(everywhere, ChannelSMS) {
+		t.Error("a notification without channels should go everywhere")
+	}
+	if !allowsChannel(emailOnly, ChannelEmail) {
+		t.Error("email refused for an email notification")
+	}
+	if allowsChannel(emailOnly, ChannelSMS) || allowsChannel(emailOnly, ChannelInApp) {
+		t.Error("channels outside the notification's list were allowed")
+	}
+}
+
+func TestDispatcherSendFiltersChannels(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t)
+
+	email := &fakeChannel{name: ChannelEmail, failures: 1}
+	sms := &fakeChannel{name: ChannelSMS, disabled: true}
+	push := &fakeChannel{name: ChannelWebPush}
+	d := &Dispatcher{Channels: []Channel{email, sms, push}, MaxAttempts: 3, Backoff: time.Millisecond}
+
+	// Restricted to email and SMS; SMS is not enabled for this user
+	if err := d.Send(models.Notification{UserID: user.ID, Content: "Hello", Channels: models.StringList{ChannelEmail, ChannelSMS}}); err != nil {
+		t.Fatal(err)
+	}
+	d.Wait()
+
+	if email.deliveries() != 2 {
+		t.Errorf("email delivered %d times, want one failure and one retry", email.deliveries())
+	}
+	if sms.deliveries() != 0 || push.deliveries() != 0 {
+		t.Errorf("sms delivered %d times and push %d times, want none", sms.deliveries(), push.deliveries())
+	}
+
+	var inApp int64
+	config.DB.Model(&models.Notification{}).Where("user_id = ?", user.ID).Count(&inApp)
+	if inApp != 0 {
+		t.Errorf("stored %d in-app notifications for an email and SMS only notification", inApp)
+	}
+
+	var statuses []string
+	config.DB.Model(&models.NotificationDelivery{}).
+		Where("user_id = ? AND channel = ?", user.ID, ChannelEmail).Order("attempt").Pluck("status", &statuses)
+	if len(statuses) != 2 || statuses[0] != models.DeliveryStatusFailed || statuses[1] != models.DeliveryStatusSent {
+		t.Errorf("email attempts logged as %v, want [failed sent]", statuses)
+	}
+}
+
+func TestDispatcherSendFollowsPreferences(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t)
+
+	email := &fakeChannel{name: ChannelEmail}
+	d := &Dispatcher{Channels: []Channel{email}, MaxAttempts: 1}
+
+	// Marketing stays out of email unless the user opts in
+	if err := d.Send(models.Notification{UserID: user.ID, Content: "Offer", Category: models.NotificationCategoryMarketing}); err != nil {
+		t.Fatal(err)
+	}
+	d.Wait()
+	if email.deliveries() != 0 {
+		t.Errorf("marketing emailed without opt-in")
+	}
+
+	config.DB.Create(&models.NotificationPreference{
+		UserID:    user.ID,
+		Category:  models.NotificationCategoryMarketing,
+		Channel:   ChannelEmail,
+		Frequency: models.FrequencyInstant,
+	})
+	t.Cleanup(func() { config.DB.Where("user_id = ?", user.ID).Delete(&models.NotificationPreference{}) })
+
+	if err := d.Send(models.Notification{UserID: user.ID, Content: "Offer", Category: models.NotificationCategoryMarketing}); err != nil {
+		t.Fatal(err)
+	}
+	d.Wait()
+	if email.deliveries() != 1 {
+		t.Errorf("marketing emailed %d times after opting in, want 1", email.deliveries())
+	}
+}
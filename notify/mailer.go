@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"errors"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer sends a plain-text email.
+type Mailer interface {
+	SendMail(to, subject, body string) error
+}
+
+// SMTPMailer sends mail through an SMTP server. Pointing it at a local sink
+// such as MailHog (SMTP_HOST=localhost, SMTP_PORT=1025) captures every message
+// without sending real mail.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) SendMail(to, subject, body string) error {
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, []byte(msg))
+}
+
+// LogMailer records mail in memory and logs it instead of sending. It is only
+// for development (MAILER=log): account emails carry live sign-in links.
+type LogMailer struct {
+	mu   sync.Mutex
+	Sent []LoggedMail
+}
+
+type LoggedMail struct {
+	To      string
+	Subject string
+	Body    string
+	At      time.Time
+}
+
+func (l *LogMailer) SendMail(to, subject, body string) error {
+	l.mu.Lock()
+	l.Sent = append(l.Sent, LoggedMail{To: to, Subject: subject, Body: body, At: time.Now()})
+	l.mu.Unlock()
+	log.Printf("[MAIL] to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+func smtpMailerFromEnv() *SMTPMailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil
+	}
+	return &SMTPMailer{
+		Host:     host,
+		Port:     envOr("SMTP_PORT", "587"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     envOr("SMTP_FROM", "no-reply@rjgproperty.com"),
+	}
+}
+
+// ErrMailerNotConfigured is returned for account emails when no mailer is set up.
+var ErrMailerNotConfigured = errors.New("email is not configured")
+
+// MailerFromEnv returns an SMTP mailer when SMTP_HOST is set, a LogMailer when
+// MAILER=log, and nil otherwise.
+func MailerFromEnv() Mailer {
+	if mailer := smtpMailerFromEnv(); mailer != nil {
+		return mailer
+	}
+	if os.Getenv("MAILER") == "log" {
+		return &LogMailer{}
+	}
+	return nil
+}
+
+var mailer Mailer
+
+// SetMailer replaces the mailer used for account emails. With nil, account
+// emails fail with ErrMailerNotConfigured.
+func SetMailer(m Mailer) {
+	mailer = m
+}
+
+// SendAccountEmail sends one of the transactional account emails, such as
+// verify_email or reset_password, linking to path on the frontend. These go
+// out regardless of notification preferences.
+func SendAccountEmail(name, email, kind, path string) error {
+	if mailer == nil {
+		return ErrMailerNotConfigured
+	}
+	subject, body, err := renderAccountEmail(name, kind, path)
+	if err != nil {
+		return err
+	}
+	return mailer.SendMail(email, subject, body)
+}
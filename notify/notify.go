@@ -0,0 +1,88 @@
+// Package notify is the single place notifications are created. The dispatcher
+// stores the in-app notification, pushes it to the user's open WebSocket
+// connections and delivers it over email, SMS and web push where the user opted in.
+package notify
+
+import (
+	"fmt"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"realstate-backend/ws"
+)
+
+var (
+	hub        *ws.Hub
+	dispatcher = &Dispatcher{MaxAttempts: 1}
+)
+
+// SetHub wires the WebSocket hub used for realtime delivery. Until it is set,
+// notifications are only stored.
+func SetHub(h *ws.Hub) {
+	hub = h
+}
+
+// SetDispatcher replaces the dispatcher used by Send. Without one, only in-app
+// notifications are delivered.
+func SetDispatcher(d *Dispatcher) {
+	dispatcher = d
+}
+
+// Send notifies notification.UserID on every channel they have enabled.
+// Category and Severity default to system/info, and ActionURL is derived from
+// the entity reference when not given.
+func Send(notification models.Notification) error {
+	return dispatcher.Send(notification)
+}
+
+// Frontend routes for each entity type a notification can point at.
+var entityPaths = map[string]string{
+	"property":    "/properties/%d",
+	"requirement": "/requirements/%d",
+	"inquiry":     "/inquiries/%d",
+	"chat_thread": "/chat/%d",
+}
+
+func normalize(notification *models.Notification) {
+	if notification.Category == "" {
+		notification.Category = models.NotificationCategorySystem
+	}
+	if notification.Severity == "" {
+		notification.Severity = models.SeverityInfo
+	}
+	notification.Type = notification.Category
+	if notification.ActionURL == "" && notification.EntityID != nil {
+		if path, ok := entityPaths[notification.EntityType]; ok {
+			notification.ActionURL = fmt.Sprintf(path, *notification.EntityID)
+		}
+	}
+}
+
+// UnreadCount returns how many of the user's notifications are unread.
+func UnreadCount(userID uint) int64 {
+	var count int64
+	config.DB.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&count)
+	return count
+}
+
+func pushNotification(notification models.Notification) {
+	if hub == nil {
+		return
+	}
+	hub.BroadcastToUser(notification.UserID, map[string]interface{}{
+		"type":         "NOTIFICATION",
+		"notification": notification,
+		"unread_count": UnreadCount(notification.UserID),
+	})
+}
+
+// PushUnreadCount tells the user's open connections their current unread count,
+// e.g. after notifications were read in another tab.
+func PushUnreadCount(userID uint) {
+	if hub == nil {
+		return
+	}
+	hub.BroadcastToUser(userID, map[string]interface{}{
+		"type":         "NOTIFICATION_COUNT",
+		"unread_count": UnreadCount(userID),
+	})
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"realstate-backend/config"
+	"realstate-backend/models"
+)
+
+// Frequencies each channel supports. Only channels that can batch messages
+// offer digests.
+var channelFrequencies = map[string][]string{
+	ChannelInApp:   {models.FrequencyInstant, models.FrequencyOff},
+	ChannelEmail:   {models.FrequencyInstant, models.FrequencyDaily, models.FrequencyWeekly, models.FrequencyOff},
+	ChannelSMS:     {models.FrequencyInstant, models.FrequencyOff},
+	ChannelWebPush: {models.FrequencyInstant, models.FrequencyOff},
+}
+
+// PreferenceChannels lists the channels users can set preferences for, in display order.
+var PreferenceChannels = []string{ChannelInApp, ChannelEmail, ChannelSMS, ChannelWebPush}
+
+// ChannelFrequencies returns the options a user can pick for a channel.
+func ChannelFrequencies(channel string) []string {
+	return channelFrequencies[channel]
+}
+
+// ValidFrequency reports whether frequency is allowed on channel.
+func ValidFrequency(channel, frequency string) bool {
+	for _, f := range channelFrequencies[channel] {
+		if f == frequency {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultFrequency applies when a user has not chosen. Marketing stays out of
+// email and SMS unless the user opts in.
+func defaultFrequency(category, channel string) string {
+	if category == models.NotificationCategoryMarketing && channel != ChannelInApp {
+		return models.FrequencyOff
+	}
+	return models.FrequencyInstant
+}
+
+// Preferences returns the user's frequency for every category and channel,
+// with defaults filled in for anything they have not set.
+func Preferences(userID uint) map[string]map[string]string {
+	matrix := make(map[string]map[string]string)
+	for _, category := range models.NotificationCategories {
+		matrix[category] = make(map[string]string)
+		for _, channel := range PreferenceChannels {
+			matrix[category][channel] = defaultFrequency(category, channel)
+		}
+	}
+
+	var saved []models.NotificationPreference
+	config.DB.Where("user_id = ?", userID).Find(&saved)
+	for _, pref := range saved {
+		if row, ok := matrix[pref.Category]; ok {
+			row[pref.Channel] = pref.Frequency
+		}
+	}
+	return matrix
+}
+
+func frequencyIn(matrix map[string]map[string]string, category, channel string) string {
+	if f, ok := matrix[category][channel]; ok {
+		return f
+	}
+	return defaultFrequency(category, channel)
+}
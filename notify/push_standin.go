@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PushStandIn is a local stand-in for a browser push service. It accepts Web
+// Push requests like FCM or Mozilla autopush would, records them, and answers
+// 410 Gone for endpoints ending in "/gone" so subscription cleanup can be
+// exercised. A GET returns everything received so far.
+type PushStandIn struct {
+	mu       sync.Mutex
+	Received []StandInPush
+}
+
+type StandInPush struct {
+	Endpoint        string    `json:"endpoint"`
+	Authorization   string    `json:"authorization"`
+	ContentEncoding string    `json:"content_encoding"`
+	TTL             string    `json:"ttl"`
+	Urgency         string    `json:"urgency"`
+	Bytes           int       `json:"bytes"`
+	At              time.Time `json:"at"`
+}
+
+func (p *PushStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.Received)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/gone") {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "vapid ") || r.Header.Get("TTL") == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	push := StandInPush{
+		Endpoint:        r.URL.Path,
+		Authorization:   r.Header.Get("Authorization"),
+		ContentEncoding: r.Header.Get("Content-Encoding"),
+		TTL:             r.Header.Get("TTL"),
+		Urgency:         r.Header.Get("Urgency"),
+		Bytes:           len(body),
+		At:              time.Now(),
+	}
+	p.mu.Lock()
+	p.Received = append(p.Received, push)
+	p.mu.Unlock()
+	log.Printf("[PUSH] %s (%d bytes)", push.Endpoint, push.Bytes)
+
+	w.WriteHeader(http.StatusCreated)
+}
@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateData is what channel templates can refer to.
+type templateData struct {
+	Name     string
+	Content  string
+	Category string
+	SiteName string
+	Link     string // Absolute URL of the notification's action, if any
+}
+
+type emailTemplate struct {
+	Subject *template.Template
+	Body    *template.Template
+}
+
+func mustEmail(subject, body string) emailTemplate {
+	return emailTemplate{
+		Subject: template.Must(template.New("subject").Parse(subject)),
+		Body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+const emailFooter = `{{if .Link}}
+
+Open: {{.Link}}{{end}}
+
+You are receiving this because email notifications are on for your {{.SiteName}} account. You can turn them off in your profile settings.`
+
+// Email templates by notification category; "default" covers the rest.
+var emailTemplates = map[string]emailTemplate{
+	"default": mustEmail("{{.SiteName}}: new notification",
+		"Hi {{.Name}},\n\n{{.Content}}"+emailFooter),
+	"inquiry": mustEmail("{{.SiteName}}: new inquiry",
+		"Hi {{.Name}},\n\n{{.Content}}\n\nLog in to reply to the inquiry."+emailFooter),
+	"chat": mustEmail("{{.SiteName}}: you have a new message",
+		"Hi {{.Name}},\n\n{{.Content}}\n\nLog in to continue the conversation."+emailFooter),
+	"payment": mustEmail("{{.SiteName}}: payment update",
+		"Hi {{.Name}},\n\n{{.Content}}"+emailFooter),
+}
+
+// SMS templates by notification category. Categories without one are never texted, so
+// routine notifications do not run up gateway costs.
+var smsTemplates = map[string]*template.Template{
+	"inquiry": template.Must(template.New("inquiry").Parse("{{.SiteName}}: {{.Content}}")),
+	"payment": template.Must(template.New("payment").Parse("{{.SiteName}}: {{.Content}}")),
+}
+
+// Texts longer than this are truncated to a single SMS segment.
+const maxSMSLength = 160
+
+func siteName() string {
+	var cfg models.SiteConfig
+	if err := config.DB.Where("key = ?", "site_name").First(&cfg).Error; err == nil && cfg.Value != "" {
+		return cfg.Value
+	}
+	return "RJG Property Connect"
+}
+
+func newTemplateData(user models.User, notification models.Notification) templateData {
+	link := ""
+	if base := os.Getenv("FRONTEND_URL"); base != "" && notification.ActionURL != "" {
+		link = strings.TrimRight(base, "/") + notification.ActionURL
+	}
+	return templateData{Name: user.Name, Content: notification.Content, Category: notification.Category, SiteName: siteName(), Link: link}
+}
+
+func renderEmail(user models.User, notification models.Notification) (string, string, error) {
+	tmpl, ok := emailTemplates[notification.Category]
+	if !ok {
+		tmpl = emailTemplates["default"]
+	}
+	data := newTemplateData(user, notification)
+
+	var subject, body bytes.Buffer
+	if err := tmpl.Subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.Body.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
+
+func renderSMS(user models.User, notification models.Notification) (string, error) {
+	tmpl, ok := smsTemplates[notification.Category]
+	if !ok {
+		return "", fmt.Errorf("no sms template for %q notifications", notification.Category)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, newTemplateData(user, notification)); err != nil {
+		return "", err
+	}
+
+	text := []rune(body.String())
+	if len(text) > maxSMSLength {
+		text = append(text[:maxSMSLength-3], []rune("...")...)
+	}
+	return string(text), nil
+}
+
+var digestTemplate = mustEmail("{{.SiteName}}: your {{.Period}} summary ({{len .Items}} updates)",
+	`Hi {{.Name}},
+
+Here is what happened since your last {{.Period}} summary:
+{{range .Items}}
+- [{{.CreatedAt.Format "02 Jan 15:04"}}] {{.Content}}{{if .Link}}
+  {{.Link}}{{end}}
+{{end}}
+You can change how often you get these emails in your notification settings.`)
+
+type digestEntry struct {
+	CreatedAt time.Time
+	Content   string
+	Link      string
+}
+
+func renderDigest(user models.User, frequency string, items []models.NotificationDigestItem) (string, string, error) {
+	base := newTemplateData(user, models.Notification{})
+	period := map[string]string{models.FrequencyDaily: "daily", models.FrequencyWeekly: "weekly"}[frequency]
+
+	data := struct {
+		templateData
+		Period string
+		Items  []digestEntry
+	}{templateData: base, Period: period}
+
+	frontend := strings.TrimRight(os.Getenv("FRONTEND_URL"), "/")
+	for _, item := range items {
+		entry := digestEntry{CreatedAt: item.CreatedAt, Content: item.Content}
+		if frontend != "" && item.ActionURL != "" {
+			entry.Link = frontend + item.ActionURL
+		}
+		data.Items = append(data.Items, entry)
+	}
+
+	var subject, body bytes.Buffer
+	if err := digestTemplate.Subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := digestTemplate.Body.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
+
+// Transactional account emails, by kind.
+var accountEmailTemplates = map[string]emailTemplate{
+	"verify_email": mustEmail("{{.SiteName}}: confirm your email address",
+		`Hi {{.Name}},
+
+Please confirm your email address by opening this link:
+
+{{.Link}}
+
+The link expires in 24 hours. If you did not create an account, you can ignore this email.`),
+	"reset_password": mustEmail("{{.SiteName}}: reset your password",
+		`Hi {{.Name}},
+
+We received a request to reset your password. Choose a new one here:
+
+{{.Link}}
+
+The link expires in 1 hour and can only be used once. If you did not ask for this, you can ignore this email; your password has not changed.`),
+}
+
+func renderAccountEmail(name, kind, path string) (string, string, error) {
+	tmpl, ok := accountEmailTemplates[kind]
+	if !ok {
+		return "", "", fmt.Errorf("no account email template %q", kind)
+	}
+
+	frontend := strings.TrimRight(os.Getenv("FRONTEND_URL"), "/")
+	if frontend == "" {
+		frontend = "http://localhost:3000"
+	}
+	data := templateData{Name: name, SiteName: siteName(), Link: frontend + path}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.Subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.Body.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
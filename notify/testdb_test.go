@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"os"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB points config.DB at the Postgres database in TEST_DATABASE_URL
+// for the test, or skips it when none is configured.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Notification{}, &models.NotificationDelivery{}, &models.NotificationPreference{}); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() { config.DB = previous })
+}
+
+// createTestUser adds a user that is removed, with everything sent to them,
+// when the test ends.
+func createTestUser(t *testing.T) models.User {
+	t.Helper()
+	user := models.User{
+		Name:               "Test User",
+		Email:              "notify-test-" + time.Now().Format("150405.000000000") + "@example.com",
+		EmailNotifications: true,
+		InAppNotifications: true,
+	}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() {
+		config.DB.Where("user_id = ?", user.ID).Delete(&models.NotificationDelivery{})
+		config.DB.Where("user_id = ?", user.ID).Delete(&models.Notification{})
+		config.DB.Unscoped().Delete(&user)
+	})
+	return user
+}
@@ -0,0 +1,434 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Record size advertised in the aes128gcm header; payloads are a single record.
+const pushRecordSize = 4096
+
+// WebPushChannel sends notifications to a user's registered browsers when they
+// have no open WebSocket. Payloads are encrypted per RFC 8291 and requests are
+// signed with VAPID (RFC 8292).
+type WebPushChannel struct {
+	PublicKey  string // Application server key handed to browsers, base64url
+	privateKey *ecdsa.PrivateKey
+	Subject    string // Contact for push services, e.g. mailto:ops@example.com
+	TTL        int    // Seconds the push service may hold an undelivered message
+	Client     *http.Client
+}
+
+// NewWebPushChannel builds a channel from a base64url-encoded raw P-256 private key.
+func NewWebPushChannel(privateKey, subject string) (*WebPushChannel, error) {
+	d, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(privateKey, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	pub := key.PublicKey().Bytes() // 0x04 || X || Y
+
+	return &WebPushChannel{
+		PublicKey: base64.RawURLEncoding.EncodeToString(pub),
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:]),
+			},
+			D: new(big.Int).SetBytes(d),
+		},
+		Subject: subject,
+		TTL:     24 * 60 * 60,
+		Client:  pushClient(),
+	}, nil
+}
+
+// pushClient returns the HTTP client pushes are sent with. Outside of the
+// stand-in setup it refuses to connect to internal addresses, so a subscription
+// whose host later resolves somewhere private still cannot reach it.
+func pushClient() *http.Client {
+	if pushStandInEnabled() {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip, err := netip.ParseAddr(host); err != nil || !isPublicAddress(ip) {
+				return fmt.Errorf("refusing to push to internal address %s", host)
+			}
+			return nil
+		},
+	}).DialContext
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+// pushStandInEnabled reports whether the local push stand-in is mounted, in
+// which case endpoints on this machine and plain http are allowed.
+func pushStandInEnabled() bool {
+	return os.Getenv("WEB_PUSH_STANDIN") == "true"
+}
+
+// reservedPrefixes are the ranges of the IANA IPv4 and IPv6 special-purpose
+// address registries that are not globally reachable, plus multicast.
+var reservedPrefixes = func() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, p := range []string{
+		"0.0.0.0/8",       // "This network"
+		"10.0.0.0/8",      // Private
+		"100.64.0.0/10",   // Shared address space (carrier-grade NAT)
+		"127.0.0.0/8",     // Loopback
+		"169.254.0.0/16",  // Link local, including cloud metadata services
+		"172.16.0.0/12",   // Private
+		"192.0.0.0/24",    // IETF protocol assignments
+		"192.0.2.0/24",    // Documentation (TEST-NET-1)
+		"192.31.196.0/24", // AS112-v4
+		"192.52.193.0/24", // AMT
+		"192.88.99.0/24",  // Deprecated 6to4 relay anycast
+		"192.168.0.0/16",  // Private
+		"192.175.48.0/24", // Direct delegation AS112 service
+		"198.18.0.0/15",   // Benchmarking
+		"198.51.100.0/24", // Documentation (TEST-NET-2)
+		"203.0.113.0/24",  // Documentation (TEST-NET-3)
+		"224.0.0.0/4",     // Multicast
+		"240.0.0.0/4",     // Reserved, including limited broadcast
+		"::/128",          // Unspecified
+		"::1/128",         // Loopback
+		"::/96",           // Deprecated IPv4-compatible addresses
+		"64:ff9b::/96",    // IPv4/IPv6 translation (NAT64)
+		"64:ff9b:1::/48",  // Local-use IPv4/IPv6 translation
+		"100::/64",        // Discard-only
+		"2001::/23",       // IETF protocol assignments, including Teredo
+		"2001:db8::/32",   // Documentation
+		"2002::/16",       // 6to4
+		"3fff::/20",       // Documentation
+		"5f00::/16",       // Segment routing SIDs
+		"fc00::/7",        // Unique local
+		"fe80::/10",       // Link local
+		"fec0::/10",       // Deprecated site local
+		"ff00::/8",        // Multicast
+	} {
+		prefixes = append(prefixes, netip.MustParsePrefix(p))
+	}
+	return prefixes
+}()
+
+// isPublicAddress reports whether ip is somewhere a push service could live.
+// IPv4-mapped IPv6 addresses are judged by the IPv4 address they carry.
+func isPublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap().WithZone("")
+	if !ip.IsValid() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidatePushEndpoint checks the endpoint a browser subscribed with. Push
+// services are public https origins; anything else would have us POST to
+// hosts of the subscriber's choosing, including our own network.
+func ValidatePushEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return errors.New("invalid endpoint")
+	}
+	if pushStandInEnabled() && (u.Scheme == "http" || u.Scheme == "https") {
+		return nil
+	}
+	if u.Scheme != "https" {
+		return errors.New("endpoint must use https")
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return errors.New("endpoint host could not be resolved")
+	}
+	for _, ip := range ips {
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok || !isPublicAddress(addr) {
+			return errors.New("endpoint must be a public push service")
+		}
+	}
+	return nil
+}
+
+func (w *WebPushChannel) Name() string { return ChannelWebPush }
+
+// Enabled only when the user is not connected; an open tab already got the
+// notification over the WebSocket.
+func (w *WebPushChannel) Enabled(user models.User, notification models.Notification) bool {
+	if hub != nil && hub.IsOnline(user.ID) {
+		return false
+	}
+	var count int64
+	config.DB.Model(&models.PushSubscription{}).Where("user_id = ?", user.ID).Count(&count)
+	return count > 0
+}
+
+// Deliver pushes to every live subscription of the user. It fails only when no
+// subscription accepted the message, so a retry does not repeat successful pushes
+// more than necessary. Subscriptions the push service reports gone are deleted.
+func (w *WebPushChannel) Deliver(user models.User, notification models.Notification) error {
+	var subscriptions []models.PushSubscription
+	config.DB.Where("user_id = ?", user.ID).Find(&subscriptions)
+
+	payload, err := json.Marshal(pushPayload(notification))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var failures []string
+	delivered := 0
+	for _, sub := range subscriptions {
+		if sub.ExpiresAt != nil && now.After(*sub.ExpiresAt) {
+			config.DB.Delete(&sub)
+			continue
+		}
+
+		status, err := w.push(sub, payload, notification.Severity == models.SeverityCritical)
+		switch {
+		case err != nil:
+			failures = append(failures, err.Error())
+		case status == http.StatusNotFound || status == http.StatusGone:
+			// The browser unsubscribed or the subscription expired
+			config.DB.Delete(&sub)
+		case status >= 300:
+			failures = append(failures, fmt.Sprintf("push service returned %d", status))
+		default:
+			delivered++
+			config.DB.Model(&sub).Update("last_used_at", now)
+		}
+	}
+
+	if delivered == 0 && len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func pushPayload(notification models.Notification) map[string]interface{} {
+	titles := map[string]string{
+		models.NotificationCategoryInquiry: "New inquiry",
+		models.NotificationCategoryChat:    "New message",
+		models.NotificationCategoryPayment: "Payment update",
+	}
+	title, ok := titles[notification.Category]
+	if !ok {
+		title = "RJG Property Connect"
+	}
+
+	return map[string]interface{}{
+		"title":           title,
+		"body":            notification.Content,
+		"url":             notification.ActionURL,
+		"category":        notification.Category,
+		"severity":        notification.Severity,
+		"notification_id": notification.ID,
+		"tag":             notification.Category,
+	}
+}
+
+func (w *WebPushChannel) push(sub models.PushSubscription, payload []byte, urgent bool) (int, error) {
+	body, err := encryptPushPayload(sub, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	authorization, err := w.vapidAuthorization(sub.Endpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", fmt.Sprint(w.TTL))
+	req.Header.Set("Authorization", authorization)
+	if urgent {
+		req.Header.Set("Urgency", "high")
+	} else {
+		req.Header.Set("Urgency", "normal")
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// vapidAuthorization signs a short-lived token for the push service's origin.
+func (w *WebPushChannel) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": w.Subject,
+	})
+	signed, err := token.SignedString(w.privateKey)
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + signed + ", k=" + w.PublicKey, nil
+}
+
+// encryptPushPayload encodes payload as a single aes128gcm record (RFC 8188)
+// keyed for the subscription as described in RFC 8291.
+func encryptPushPayload(sub models.PushSubscription, payload []byte) ([]byte, error) {
+	uaPublic, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(sub.P256dh, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh: %w", err)
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(sub.Auth, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %w", err)
+	}
+
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptPushRecord(uaPublic, authSecret, asKey, salt, payload)
+}
+
+// encryptPushRecord does the work of encryptPushPayload with the sender key
+// and salt supplied, which are random for every real message.
+func encryptPushRecord(uaPublic, authSecret []byte, asKey *ecdh.PrivateKey, salt, payload []byte) ([]byte, error) {
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh: %w", err)
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	sharedSecret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0x02 marks the last (and only) record; no padding
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	if len(plaintext)+gcm.Overhead() > pushRecordSize {
+		return nil, fmt.Errorf("push payload too large")
+	}
+
+	var header bytes.Buffer
+	header.Write(salt)
+	binary.Write(&header, binary.BigEndian, uint32(pushRecordSize))
+	header.WriteByte(byte(len(asPublic)))
+	header.Write(asPublic)
+
+	return gcm.Seal(header.Bytes(), nonce, plaintext, nil), nil
+}
+
+// GenerateVAPIDKeys returns a new base64url key pair for VAPID_PRIVATE_KEY and
+// the matching public key.
+func GenerateVAPIDKeys() (privateKey, publicKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// WebPushPublicKey returns the application server key browsers subscribe with,
+// or "" when Web Push is not configured.
+func WebPushPublicKey() string {
+	for _, channel := range dispatcher.Channels {
+		if w, ok := channel.(*WebPushChannel); ok {
+			return w.PublicKey
+		}
+	}
+	return ""
+}
+
+// ValidatePushKeys checks the keys a browser returned from pushManager.subscribe.
+func ValidatePushKeys(p256dh, auth string) error {
+	uaPublic, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(p256dh, "="))
+	if err != nil {
+		return errors.New("p256dh must be base64url encoded")
+	}
+	if _, err := ecdh.P256().NewPublicKey(uaPublic); err != nil {
+		return errors.New("p256dh is not a P-256 public key")
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(auth, "="))
+	if err != nil || len(secret) != 16 {
+		return errors.New("auth must be a 16-byte base64url secret")
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"net/netip"
+	"realstate-backend/models"
+	"testing"
+)
+
+func b64(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return b
+}
+
+// The worked example in RFC 8291, Appendix A.
+func TestEncryptPushRecordRFC8291(t *testing.T) {
+	asKey, err := ecdh.P256().NewPrivateKey(b64(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPublic := b64(t, "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4")
+	authSecret := b64(t, "BTBZMqHH6r4Tts7J_aSIgg")
+	salt := b64(t, "DGv6ra1nlYgDCS1FRnbzlw")
+
+	got, err := encryptPushRecord(uaPublic, authSecret, asKey, salt, []byte("When I grow up, I want to be a watermelon"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if enc := base64.RawURLEncoding.EncodeToString(got); enc != want {
+		t.Errorf("encrypted record\n got %s\nwant %s", enc, want)
+	}
+}
+
+func TestEncryptPushPayloadRejectsOversizedPayload(t *testing.T) {
+	uaKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := models.PushSubscription{
+		P256dh: base64.RawURLEncoding.EncodeToString(uaKey.PublicKey().Bytes()),
+		Auth:   "BTBZMqHH6r4Tts7J_aSIgg",
+	}
+
+	if _, err := encryptPushPayload(sub, make([]byte, pushRecordSize)); err == nil {
+		t.Error("expected a payload larger than one record to be refused")
+	}
+	if _, err := encryptPushPayload(sub, []byte("{}")); err != nil {
+		t.Errorf("small payload: %v", err)
+	}
+}
+
+func TestValidatePushEndpoint(t *testing.T) {
+	t.Setenv("WEB_PUSH_STANDIN", "")
+
+	for _, endpoint := range []string{
+		"http://8.8.8.8/push",
+		"https://127.0.0.1/push",
+		"https://10.1.2.3/push",
+		"https://192.168.1.4/push",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]/push",
+		"https://0.0.0.0/push",
+		"ftp://8.8.8.8/push",
+		"not a url",
+	} {
+		if err := ValidatePushEndpoint(endpoint); err == nil {
+			t.Errorf("%s: expected to be refused", endpoint)
+		}
+	}
+
+	if err := ValidatePushEndpoint("https://8.8.8.8/push/abc"); err != nil {
+		t.Errorf("public https endpoint refused: %v", err)
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	for _, addr := range []string{
+		"0.1.2.3",
+		"10.0.0.1",
+		"100.64.0.1",
+		"127.0.0.1",
+		"169.254.169.254",
+		"172.16.5.4",
+		"192.0.0.170",
+		"192.168.1.4",
+		"198.18.0.1",
+		"203.0.113.9",
+		"224.0.0.251",
+		"255.255.255.255",
+		"::",
+		"::1",
+		"::ffff:10.0.0.1",
+		"::ffff:169.254.169.254",
+		"64:ff9b::a9fe:a9fe",
+		"64:ff9b:1::1",
+		"2001::1",
+		"2001:db8::1",
+		"2002:a9fe:a9fe::1",
+		"fd00::1",
+		"fe80::1%eth0",
+		"ff02::1",
+	} {
+		if isPublicAddress(netip.MustParseAddr(addr)) {
+			t.Errorf("%s: expected to be reserved", addr)
+		}
+	}
+
+	for _, addr := range []string{"8.8.8.8", "142.250.183.78", "::ffff:8.8.8.8", "2607:f8b0:4009:80b::200e"} {
+		if !isPublicAddress(netip.MustParseAddr(addr)) {
+			t.Errorf("%s: expected to be public", addr)
+		}
+	}
+}
+
+func TestValidatePushEndpointStandIn(t *testing.T) {
+	t.Setenv("WEB_PUSH_STANDIN", "true")
+
+	if err := ValidatePushEndpoint("http://localhost:8080/api/dev/push/abc"); err != nil {
+		t.Errorf("stand-in endpoint refused: %v", err)
+	}
+	if err := ValidatePushEndpoint("ftp://localhost/abc"); err == nil {
+		t.Error("expected a non-http scheme to be refused")
+	}
+}
@@ -0,0 +1,209 @@
+// Package rbac resolves what a user is allowed to do. Permissions are grouped
+// into roles stored in the database; a user gets the role named by their
+// account role plus any roles granted to them.
+package rbac
+
+import (
+	"log"
+	"realstate-backend/config"
+	"realstate-backend/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	PermAdminAccess        = "admin.access" // Sign in to the admin panel; also makes 2FA mandatory
+	PermUsersRead          = "users.read"
+	PermUsersManage        = "users.manage" // Change account role and badge, ban and delete
+	PermUsersMessage       = "users.message"
+	PermUsers2FAReset      = "users.2fa_reset"
+	PermListingsVerify     = "listings.verify"     // Verify, feature and hide properties and requirements
+	PermListingsManage     = "listings.manage"     // Edit and delete anyone's listings
+	PermApplicationsReview = "applications.review" // Approve developer and broker onboarding
+	PermReportsManage      = "reports.manage"
+	PermChatModerate       = "chat.moderate" // Open, export and observe any conversation
+	PermPaymentsRead       = "payments.read"
+	PermStatsRead          = "stats.read"
+	PermDataExport         = "data.export"
+	PermNotificationsRead  = "notifications.read" // Delivery logs
+	PermBroadcastsManage   = "broadcasts.manage"
+	PermCMSManage          = "cms.manage"
+	PermRolesManage        = "roles.manage"
+)
+
+// AccountRoles are the roles users hold as platform members. They carry no
+// permissions of their own; staff roles are rows in the roles table.
+var AccountRoles = []string{"seeker", "owner", "developer", "broker"}
+
+// IsAccountRole reports whether name is one of AccountRoles.
+func IsAccountRole(name string) bool {
+	for _, r := range AccountRoles {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions lists every permission with what it allows, in display order.
+var Permissions = []models.Permission{
+	{Key: PermAdminAccess, Description: "Sign in to the admin panel"},
+	{Key: PermUsersRead, Description: "View users"},
+	{Key: PermUsersManage, Description: "Change account roles and badges, ban and delete users"},
+	{Key: PermUsersMessage, Description: "Send messages to users"},
+	{Key: PermUsers2FAReset, Description: "Reset a user's two-factor authentication"},
+	{Key: PermListingsVerify, Description: "Verify, feature and hide listings and requirements"},
+	{Key: PermListingsManage, Description: "Edit and delete any listing or requirement"},
+	{Key: PermApplicationsReview, Description: "Review developer and broker applications"},
+	{Key: PermReportsManage, Description: "Review and resolve reports"},
+	{Key: PermChatModerate, Description: "Open, export and observe any conversation"},
+	{Key: PermPaymentsRead, Description: "View payments"},
+	{Key: PermStatsRead, Description: "View platform statistics"},
+	{Key: PermDataExport, Description: "Export platform data"},
+	{Key: PermNotificationsRead, Description: "View notification delivery logs"},
+	{Key: PermBroadcastsManage, Description: "Create and send broadcasts, run digests"},
+	{Key: PermCMSManage, Description: "Edit site content and configuration"},
+	{Key: PermRolesManage, Description: "Manage roles, permissions and role grants"},
+}
+
+// defaultRoles are created on first start. Only admin is kept in sync with
+// the full permission list; the others can be edited afterwards.
+var defaultRoles = []struct {
+	Name        string
+	Description string
+	Permissions []string
+}{
+	{"admin", "Full access", nil},
+	{"moderator", "Verifies listings and onboarding applications", []string{PermAdminAccess, PermListingsVerify, PermApplicationsReview}},
+	{"support", "Looks up and messages users", []string{PermAdminAccess, PermUsersRead, PermUsersMessage}},
+	{"finance", "Views payments", []string{PermAdminAccess, PermPaymentsRead}},
+}
+
+// Seed creates missing permissions and default roles.
+func Seed() {
+	for _, p := range Permissions {
+		perm := p
+		if err := config.DB.Where(models.Permission{Key: perm.Key}).Assign(models.Permission{Description: perm.Description}).FirstOrCreate(&perm).Error; err != nil {
+			log.Printf("Failed to seed permission %s: %v", p.Key, err)
+		}
+	}
+
+	var all []models.Permission
+	config.DB.Find(&all)
+	byKey := make(map[string]models.Permission)
+	for _, p := range all {
+		byKey[p.Key] = p
+	}
+
+	for _, def := range defaultRoles {
+		var role models.Role
+		created := false
+		if err := config.DB.Where("name = ?", def.Name).First(&role).Error; err != nil {
+			role = models.Role{Name: def.Name, Description: def.Description, IsSystem: true}
+			if err := config.DB.Create(&role).Error; err != nil {
+				log.Printf("Failed to seed role %s: %v", def.Name, err)
+				continue
+			}
+			created = true
+		}
+
+		var perms []models.Permission
+		switch {
+		case def.Permissions == nil:
+			perms = all
+		case created:
+			for _, key := range def.Permissions {
+				perms = append(perms, byKey[key])
+			}
+		default:
+			continue
+		}
+		if err := config.DB.Model(&role).Association("Permissions").Replace(perms); err != nil {
+			log.Printf("Failed to seed permissions for role %s: %v", def.Name, err)
+		}
+	}
+
+	InvalidateAll()
+}
+
+type cacheEntry struct {
+	perms   map[string]bool
+	expires time.Time
+}
+
+// Permissions are cached briefly; changes made through this process
+// invalidate the cache immediately.
+const cacheTTL = 30 * time.Second
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[uint]cacheEntry)
+)
+
+// UserPermissions returns the set of permission keys userID holds.
+func UserPermissions(userID uint) map[string]bool {
+	cacheMu.Lock()
+	entry, ok := cache[userID]
+	cacheMu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.perms
+	}
+
+	var keys []string
+	config.DB.Raw(`SELECT DISTINCT p.key FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		JOIN roles r ON r.id = rp.role_id
+		WHERE r.name = (SELECT role FROM users WHERE id = ? AND deleted_at IS NULL)
+			OR r.id IN (SELECT role_id FROM user_role_grants WHERE user_id = ?)`, userID, userID).Scan(&keys)
+
+	perms := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		perms[key] = true
+	}
+
+	cacheMu.Lock()
+	cache[userID] = cacheEntry{perms: perms, expires: time.Now().Add(cacheTTL)}
+	cacheMu.Unlock()
+	return perms
+}
+
+// Can reports whether userID holds every one of perms.
+func Can(userID uint, perms ...string) bool {
+	held := UserPermissions(userID)
+	for _, p := range perms {
+		if !held[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// List returns the permission keys userID holds, sorted.
+func List(userID uint) []string {
+	keys := make([]string, 0)
+	for key := range UserPermissions(userID) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// IsStaff reports whether the user can use the admin panel.
+func IsStaff(userID uint) bool {
+	return Can(userID, PermAdminAccess)
+}
+
+// Invalidate drops the cached permissions of one user.
+func Invalidate(userID uint) {
+	cacheMu.Lock()
+	delete(cache, userID)
+	cacheMu.Unlock()
+}
+
+// InvalidateAll drops every cached permission set, e.g. after a role changes.
+func InvalidateAll() {
+	cacheMu.Lock()
+	cache = make(map[uint]cacheEntry)
+	cacheMu.Unlock()
+}
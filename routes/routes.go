@@ -2,14 +2,20 @@ package routes
 
 import (
 	"log"
+	"os"
 	"realstate-backend/controllers"
 	"realstate-backend/middleware"
+	"realstate-backend/notify"
+	"realstate-backend/rbac"
 	"realstate-backend/ws"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(r *gin.Engine, hub *ws.Hub) {
+	perm := middleware.RequirePermission
+
 	api := r.Group("/api")
 	{
 		// WebSocket Route
@@ -24,10 +30,40 @@ func SetupRoutes(r *gin.Engine, hub *ws.Hub) {
 		// Auth routes
 		auth := api.Group("/auth")
 		{
-			auth.POST("/register", controllers.Register)
-			auth.POST("/login", controllers.Login)
-			auth.POST("/admin-login", controllers.AdminLogin)
+			auth.POST("/register", middleware.RateLimit(10, time.Hour), controllers.Register)
+			auth.POST("/login", middleware.RateLimit(30, time.Hour), controllers.Login)
+			auth.POST("/admin-login", middleware.RateLimit(30, time.Hour), controllers.AdminLogin)
 			auth.POST("/google", controllers.GoogleLogin)
+			auth.POST("/refresh", controllers.RefreshToken)
+			auth.POST("/logout", middleware.AuthMiddleware(), controllers.Logout)
+			auth.POST("/verify-email", middleware.RateLimit(20, time.Hour), controllers.VerifyEmail)
+			auth.POST("/verify-email/resend", middleware.AuthMiddleware(), controllers.RequestEmailVerification)
+			auth.POST("/forgot-password", middleware.RateLimit(5, time.Hour), controllers.ForgotPassword)
+			auth.POST("/reset-password", middleware.RateLimit(20, time.Hour), controllers.ResetPassword)
+			auth.POST("/otp/send", middleware.RateLimit(10, time.Hour), controllers.SendLoginOTP)
+			auth.POST("/otp/login", middleware.RateLimit(30, time.Hour), controllers.LoginWithOTP)
+			auth.POST("/2fa/verify", middleware.RateLimit(30, time.Hour), controllers.VerifyTwoFactor)
+			auth.POST("/2fa/enroll", middleware.RateLimit(10, time.Hour), controllers.StartChallengeEnrollment)
+			auth.POST("/2fa/enroll/confirm", middleware.RateLimit(30, time.Hour), controllers.ConfirmChallengeEnrollment)
+		}
+
+		// Session routes
+		sessions := api.Group("/sessions")
+		sessions.Use(middleware.AuthMiddleware())
+		{
+			sessions.GET("", controllers.GetSessions)
+			sessions.DELETE("", controllers.RevokeAllSessions)
+			sessions.DELETE("/:id", controllers.RevokeSession)
+		}
+
+		// Developer and broker onboarding
+		onboarding := api.Group("/onboarding")
+		onboarding.Use(middleware.AuthMiddleware())
+		{
+			onboarding.GET("/applications", controllers.GetMyRoleApplications)
+			onboarding.POST("/applications", middleware.RequireVerifiedEmail(), controllers.SubmitRoleApplication)
+			onboarding.DELETE("/applications/:id", controllers.WithdrawRoleApplication)
+			onboarding.GET("/applications/:id/documents/:docId", controllers.DownloadApplicationDocument)
 		}
 
 		// User routes
@@ -35,7 +71,15 @@ func SetupRoutes(r *gin.Engine, hub *ws.Hub) {
 		user.Use(middleware.AuthMiddleware())
 		{
 			user.GET("/profile", controllers.GetProfile)
+			user.GET("/permissions", controllers.GetMyPermissions)
 			user.PUT("/profile", controllers.UpdateProfile)
+			user.POST("/phone/send-otp", middleware.RateLimit(10, time.Hour), controllers.SendPhoneVerificationOTP)
+			user.POST("/phone/verify", controllers.VerifyPhone)
+			user.GET("/2fa", controllers.GetTwoFactorStatus)
+			user.POST("/2fa/setup", controllers.SetupTwoFactor)
+			user.POST("/2fa/enable", controllers.EnableTwoFactor)
+			user.POST("/2fa/disable", controllers.DisableTwoFactor)
+			user.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
 			user.DELETE("/deactivate", controllers.DeactivateAccount)
 		}
 
@@ -50,7 +94,7 @@ func SetupRoutes(r *gin.Engine, hub *ws.Hub) {
 			protected.Use(middleware.AuthMiddleware())
 			{
 				protected.GET("/me", controllers.GetMyProperties)
-				protected.POST("", controllers.CreateProperty)
+				protected.POST("", middleware.RequireVerifiedEmail(), controllers.CreateProperty)
 				protected.PUT("/:id", controllers.UpdateProperty)
 				protected.DELETE("/:id", controllers.DeleteProperty)
 			}
@@ -60,14 +104,14 @@ func SetupRoutes(r *gin.Engine, hub *ws.Hub) {
 		// Requirement routes
 		requirements := api.Group("/requirements")
 		{
-			requirements.GET("", controllers.GetRequirements)                                 // Public
-			requirements.GET("/:id", controllers.GetRequirement)                              // Public
-			requirements.POST("", middleware.AuthMiddleware(), controllers.CreateRequirement) // Protected
+			requirements.GET("", controllers.GetRequirements)                                                                    // Public
+			requirements.GET("/:id", controllers.GetRequirement)                                                                 // Public
+			requirements.POST("", middleware.AuthMiddleware(), middleware.RequireVerifiedEmail(), controllers.CreateRequirement) // Protected
 			requirements.PUT("/:id", middleware.AuthMiddleware(), controllers.UpdateRequirement)
 			requirements.DELETE("/:id", middleware.AuthMiddleware(), controllers.DeleteRequirement)
 
 			adminOnly := requirements.Group("")
-			adminOnly.Use(middleware.AuthMiddleware(), middleware.AdminOnly())
+			adminOnly.Use(middleware.AuthMiddleware(), perm(rbac.PermListingsManage))
 			{
 				// adminOnly.DELETE("/:id", controllers.DeleteRequirement) // Moved up
 			}
@@ -84,35 +128,65 @@ func SetupRoutes(r *gin.Engine, hub *ws.Hub) {
 
 		// Admin routes
 		admin := api.Group("/admin")
-		admin.Use(middleware.AuthMiddleware(), middleware.AdminOnly())
-		{
-			admin.GET("/users", controllers.GetUsers)
-			admin.PATCH("/users/:id/role", controllers.UpdateUserRole)
-			admin.PATCH("/users/:id/badge", controllers.UpdateUserBadge)
-			admin.DELETE("/users/:id", controllers.DeleteUser)
-			admin.PATCH("/users/:id/toggle-ban", controllers.ToggleUserBan)
-			admin.POST("/users/:id/message", controllers.SendUserMessage)
-			admin.GET("/payments", controllers.GetPayments)
-			admin.GET("/stats", controllers.GetStats)
-			admin.GET("/export", controllers.ExportData)
-			admin.GET("/properties", controllers.GetAllProperties)
-			admin.GET("/requirements", controllers.GetAllRequirements)
-			admin.PATCH("/properties/:id/verify", controllers.TogglePropertyVerification)
-			admin.PATCH("/properties/:id/feature", controllers.TogglePropertyFeatured)
-			admin.PATCH("/properties/:id/toggle-active", controllers.TogglePropertyActive)
-			admin.PATCH("/requirements/:id/verify", controllers.ToggleRequirementVerification)
-			admin.PATCH("/requirements/:id/toggle-active", controllers.ToggleRequirementActive)
+		admin.Use(middleware.AuthMiddleware(), perm(rbac.PermAdminAccess))
+		{
+			admin.GET("/users", perm(rbac.PermUsersRead), controllers.GetUsers)
+			admin.PATCH("/users/:id/role", perm(rbac.PermUsersManage), controllers.UpdateUserRole)
+			admin.PATCH("/users/:id/badge", perm(rbac.PermUsersManage), controllers.UpdateUserBadge)
+			admin.DELETE("/users/:id", perm(rbac.PermUsersManage), controllers.DeleteUser)
+			admin.PATCH("/users/:id/toggle-ban", perm(rbac.PermUsersManage), controllers.ToggleUserBan)
+			admin.POST("/users/:id/message", perm(rbac.PermUsersMessage), controllers.SendUserMessage)
+			admin.DELETE("/users/:id/2fa", perm(rbac.PermUsers2FAReset), controllers.ResetUserTwoFactor)
+			admin.GET("/payments", perm(rbac.PermPaymentsRead), controllers.GetPayments)
+			admin.GET("/stats", perm(rbac.PermStatsRead), controllers.GetStats)
+			admin.GET("/export", perm(rbac.PermDataExport), controllers.ExportData)
+			admin.GET("/properties", perm(rbac.PermListingsVerify), controllers.GetAllProperties)
+			admin.GET("/requirements", perm(rbac.PermListingsVerify), controllers.GetAllRequirements)
+			admin.PATCH("/properties/:id/verify", perm(rbac.PermListingsVerify), controllers.TogglePropertyVerification)
+			admin.PATCH("/properties/:id/feature", perm(rbac.PermListingsVerify), controllers.TogglePropertyFeatured)
+			admin.PATCH("/properties/:id/toggle-active", perm(rbac.PermListingsVerify), controllers.TogglePropertyActive)
+			admin.PATCH("/requirements/:id/verify", perm(rbac.PermListingsVerify), controllers.ToggleRequirementVerification)
+			admin.PATCH("/requirements/:id/toggle-active", perm(rbac.PermListingsVerify), controllers.ToggleRequirementActive)
+			admin.GET("/reports", perm(rbac.PermReportsManage), controllers.GetReports)
+			admin.PATCH("/reports/:id/resolve", perm(rbac.PermReportsManage), controllers.ResolveReport)
+			admin.PATCH("/reports/:id/dismiss", perm(rbac.PermReportsManage), controllers.DismissReport)
+			admin.GET("/notification-deliveries", perm(rbac.PermNotificationsRead), controllers.GetNotificationDeliveries)
+			admin.POST("/notification-digests/run", perm(rbac.PermBroadcastsManage), controllers.RunNotificationDigests)
+			admin.GET("/broadcasts", perm(rbac.PermBroadcastsManage), controllers.GetBroadcasts)
+			admin.POST("/broadcasts", perm(rbac.PermBroadcastsManage), controllers.CreateBroadcast)
+			admin.POST("/broadcasts/preview", perm(rbac.PermBroadcastsManage), controllers.PreviewBroadcastSegment)
+			admin.GET("/broadcasts/:id", perm(rbac.PermBroadcastsManage), controllers.GetBroadcast)
+			admin.PUT("/broadcasts/:id", perm(rbac.PermBroadcastsManage), controllers.UpdateBroadcast)
+			admin.POST("/broadcasts/:id/send", perm(rbac.PermBroadcastsManage), controllers.SendBroadcast)
+			admin.PATCH("/broadcasts/:id/cancel", perm(rbac.PermBroadcastsManage), controllers.CancelBroadcast)
+			admin.DELETE("/broadcasts/:id", perm(rbac.PermBroadcastsManage), controllers.DeleteBroadcast)
+			admin.GET("/applications", perm(rbac.PermApplicationsReview), controllers.GetRoleApplications)
+			admin.PATCH("/applications/:id/approve", perm(rbac.PermApplicationsReview), controllers.ApproveRoleApplication)
+			admin.PATCH("/applications/:id/reject", perm(rbac.PermApplicationsReview), controllers.RejectRoleApplication)
+			admin.GET("/permissions", perm(rbac.PermRolesManage), controllers.GetPermissions)
+			admin.GET("/roles", perm(rbac.PermRolesManage), controllers.GetRoles)
+			admin.POST("/roles", perm(rbac.PermRolesManage), controllers.CreateRole)
+			admin.PUT("/roles/:id", perm(rbac.PermRolesManage), controllers.UpdateRole)
+			admin.DELETE("/roles/:id", perm(rbac.PermRolesManage), controllers.DeleteRole)
+			admin.GET("/users/:id/roles", perm(rbac.PermRolesManage), controllers.GetUserRoles)
+			admin.POST("/users/:id/roles", perm(rbac.PermRolesManage), controllers.GrantUserRole)
+			admin.DELETE("/users/:id/roles/:roleId", perm(rbac.PermRolesManage), controllers.RevokeUserRole)
 		}
 
+		// Inquiry conversations run on chat threads
+		chatController := controllers.NewChatController(hub)
+
 		// Inquiry routes
 		inquiries := api.Group("/inquiries")
 		inquiries.Use(middleware.AuthMiddleware())
 		{
-			inquiries.POST("", controllers.CreateInquiry)
+			inquiries.POST("", chatController.CreateInquiry)
 			inquiries.GET("/me", controllers.GetMyInquiries)
 			inquiries.GET("/:id", controllers.GetInquiryDetail)
-			inquiries.POST("/:id/messages", controllers.SendMessage)
-			inquiries.PATCH("/:id/status", controllers.UpdateInquiryStatus)
+			inquiries.GET("/:id/export", controllers.ExportInquiry)
+			inquiries.POST("/:id/messages", chatController.SendMessage)
+			inquiries.PATCH("/:id/status", chatController.UpdateInquiryStatus)
+			inquiries.POST("/:id/share-contact", chatController.ShareInquiryContact)
 		}
 
 		// Notification routes
@@ -120,19 +194,45 @@ func SetupRoutes(r *gin.Engine, hub *ws.Hub) {
 		notifications.Use(middleware.AuthMiddleware())
 		{
 			notifications.GET("", controllers.GetNotifications)
+			notifications.GET("/unread-count", controllers.GetUnreadNotificationCount)
+			notifications.GET("/preferences", controllers.GetNotificationPreferences)
+			notifications.PUT("/preferences", controllers.UpdateNotificationPreferences)
+			notifications.DELETE("", controllers.ClearNotifications)
+			notifications.DELETE("/:id", controllers.DeleteNotification)
 			notifications.PATCH("/:id/read", controllers.MarkNotificationRead)
 			notifications.POST("/read-all", controllers.MarkAllNotificationsRead)
 		}
 
+		// Web push routes
+		push := api.Group("/push")
+		{
+			push.GET("/vapid-public-key", controllers.GetVAPIDPublicKey)
+
+			subscriptions := push.Group("/subscriptions")
+			subscriptions.Use(middleware.AuthMiddleware())
+			{
+				subscriptions.GET("", controllers.GetPushSubscriptions)
+				subscriptions.POST("", controllers.SubscribePush)
+				subscriptions.DELETE("", controllers.UnsubscribePush)
+				subscriptions.DELETE("/:id", controllers.UnsubscribePush)
+			}
+		}
+
+		// Local push service for development; subscribe with an endpoint under
+		// /api/dev/push/ to receive pushes here
+		if os.Getenv("WEB_PUSH_STANDIN") == "true" {
+			api.Any("/dev/push/*path", gin.WrapH(&notify.PushStandIn{}))
+		}
+
 		// Upload route
 		api.POST("/upload", middleware.AuthMiddleware(), controllers.UploadImages)
 
 		// CMS Routes
 		api.GET("/config", controllers.GetSiteConfig)
-		api.POST("/cms/upload", middleware.AuthMiddleware(), middleware.AdminOnly(), controllers.UploadImage)
+		api.POST("/cms/upload", middleware.AuthMiddleware(), perm(rbac.PermCMSManage), controllers.UploadImage)
 
 		adminCms := api.Group("/admin/cms")
-		adminCms.Use(middleware.AuthMiddleware(), middleware.AdminOnly())
+		adminCms.Use(middleware.AuthMiddleware(), perm(rbac.PermCMSManage))
 		{
 			adminCms.PUT("/config", controllers.UpdateSiteConfig)
 		}
@@ -145,20 +245,54 @@ func SetupRoutes(r *gin.Engine, hub *ws.Hub) {
 			bookmarks.GET("/check", controllers.IsBookmarked)
 		}
 
+		// Block routes
+		blocks := api.Group("/blocks")
+		blocks.Use(middleware.AuthMiddleware())
+		{
+			blocks.GET("", controllers.GetBlockedUsers)
+			blocks.POST("", controllers.BlockUser)
+			blocks.DELETE("/:userId", controllers.UnblockUser)
+		}
+
+		// Quick-reply template routes
+		replyTemplates := api.Group("/reply-templates")
+		replyTemplates.Use(middleware.AuthMiddleware())
+		{
+			replyTemplates.GET("", controllers.GetReplyTemplates)
+			replyTemplates.POST("", controllers.CreateReplyTemplate)
+			replyTemplates.PUT("/:id", controllers.UpdateReplyTemplate)
+			replyTemplates.DELETE("/:id", controllers.DeleteReplyTemplate)
+			replyTemplates.POST("/render", controllers.RenderReplyTemplate)
+		}
+
+		// Report route
+		api.POST("/reports", middleware.AuthMiddleware(), controllers.CreateReport)
+
 		// Chat routes
-		chatController := controllers.NewChatController(hub)
 		chat := api.Group("/chat")
 		chat.Use(middleware.AuthMiddleware())
 		{
 			chat.GET("/threads", chatController.GetMyThreads)
 			chat.GET("/threads/:id", chatController.GetThreadMessages)
+			chat.GET("/threads/:id/export", chatController.ExportThread)
 			chat.POST("/threads", chatController.CreateThread)
 			chat.POST("/threads/:id/messages", chatController.SendChatMessage)
+			chat.POST("/threads/:id/attachments", chatController.UploadChatAttachment)
+			chat.POST("/threads/:id/participants", chatController.AddParticipant)
+			chat.DELETE("/threads/:id/participants/:userId", chatController.RemoveParticipant)
+			chat.POST("/threads/:id/share-contact", chatController.ShareContact)
+			chat.PATCH("/threads/:id/archive", chatController.ArchiveThread)
+			chat.PATCH("/threads/:id/mute", chatController.MuteThread)
+			chat.PATCH("/threads/:id/pin", chatController.PinThread)
+			chat.DELETE("/threads/:id", chatController.DeleteThreadForMe)
+			chat.GET("/attachments/:attachmentId", chatController.DownloadChatAttachment)
+			chat.GET("/attachments/:attachmentId/thumbnail", chatController.GetChatAttachmentThumbnail)
 			chat.POST("/threads/:id/read", chatController.MarkThreadRead)
 			chat.PUT("/messages/:messageId", chatController.EditMessage)
 			chat.DELETE("/messages/:messageId", chatController.DeleteMessage)
 			chat.POST("/threads/:id/typing", chatController.UpdateTypingStatus)
 			chat.GET("/search", chatController.SearchMessages)
+			chat.GET("/changes", chatController.GetChanges)
 		}
 
 		// God Mode Route - Critical System Access
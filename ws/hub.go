@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -35,17 +36,24 @@ type Hub struct {
 	unregister chan *Client
 	// Mutex for concurrent access to clients
 	mu sync.Mutex
+	// In-memory typing indicators, expired by Run.
+	Typing *TypingTracker
 }
 
 func NewHub() *Hub {
-	return &Hub{
+	h := &Hub{
 		clients:    make(map[uint][]*Client),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 	}
+	h.Typing = newTypingTracker(h)
+	return h
 }
 
 func (h *Hub) Run() {
+	typingTicker := time.NewTicker(time.Second)
+	defer typingTicker.Stop()
+
 	for {
 		select {
 		case client := <-h.register:
@@ -67,8 +75,14 @@ func (h *Hub) Run() {
 					delete(h.clients, client.UserID)
 				}
 			}
+			_, stillConnected := h.clients[client.UserID]
 			h.mu.Unlock()
+			if !stillConnected {
+				h.Typing.clearUser(client.UserID)
+			}
 			log.Printf("User %d disconnected", client.UserID)
+		case <-typingTicker.C:
+			h.Typing.expire()
 		}
 	}
 }
@@ -96,6 +110,13 @@ func (h *Hub) BroadcastToUser(userID uint, message interface{}) {
 	}
 }
 
+// IsOnline reports whether the user has at least one open connection.
+func (h *Hub) IsOnline(userID uint) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients[userID]) > 0
+}
+
 // BroadcastToThread sends a message to all participants in a thread
 func (h *Hub) BroadcastToThread(threadID uint, participants []uint, message interface{}) {
 	for _, userID := range participants {
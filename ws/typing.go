@@ -0,0 +1,139 @@
+package ws
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// A typing indicator expires unless the client refreshes it within this window.
+	typingTTL = 6 * time.Second
+	// Minimum gap between accepted "started typing" updates from one user.
+	typingMinInterval = time.Second
+)
+
+type typingKey struct {
+	threadID uint
+	userID   uint
+}
+
+type typingEntry struct {
+	expiresAt  time.Time
+	recipients []uint
+}
+
+// TypingTracker keeps ephemeral typing indicators in memory. Entries expire on
+// their own, so a client that disappears mid-sentence never leaves a stale flag.
+type TypingTracker struct {
+	hub        *Hub
+	mu         sync.Mutex
+	entries    map[typingKey]*typingEntry
+	lastUpdate map[uint]time.Time
+}
+
+func newTypingTracker(hub *Hub) *TypingTracker {
+	return &TypingTracker{
+		hub:        hub,
+		entries:    make(map[typingKey]*typingEntry),
+		lastUpdate: make(map[uint]time.Time),
+	}
+}
+
+// Set records that userID started or stopped typing in a thread and notifies
+// the recipients when the state changes. Refreshes that arrive faster than
+// typingMinInterval are rejected and Set returns false.
+func (t *TypingTracker) Set(threadID, userID uint, recipients []uint, isTyping bool) bool {
+	key := typingKey{threadID: threadID, userID: userID}
+	now := time.Now()
+
+	t.mu.Lock()
+	_, wasTyping := t.entries[key]
+	if isTyping {
+		if last, ok := t.lastUpdate[userID]; ok && now.Sub(last) < typingMinInterval {
+			t.mu.Unlock()
+			return false
+		}
+		t.lastUpdate[userID] = now
+		t.entries[key] = &typingEntry{expiresAt: now.Add(typingTTL), recipients: recipients}
+	} else {
+		delete(t.entries, key)
+	}
+	t.mu.Unlock()
+
+	if isTyping != wasTyping {
+		t.broadcast(key, recipients, isTyping)
+	}
+	return true
+}
+
+// TypingUsers returns the users currently typing in a thread.
+func (t *TypingTracker) TypingUsers(threadID uint) []uint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	users := []uint{}
+	for key := range t.entries {
+		if key.threadID == threadID {
+			users = append(users, key.userID)
+		}
+	}
+	return users
+}
+
+// expire drops indicators that have not been refreshed and tells the other
+// participants the user stopped typing.
+func (t *TypingTracker) expire() {
+	now := time.Now()
+	expired := make(map[typingKey][]uint)
+
+	t.mu.Lock()
+	for key, entry := range t.entries {
+		if now.After(entry.expiresAt) {
+			expired[key] = entry.recipients
+			delete(t.entries, key)
+		}
+	}
+	for userID, last := range t.lastUpdate {
+		if now.Sub(last) > typingTTL {
+			delete(t.lastUpdate, userID)
+		}
+	}
+	t.mu.Unlock()
+
+	for key, recipients := range expired {
+		t.broadcast(key, recipients, false)
+	}
+}
+
+// clearUser stops every indicator for a user, e.g. when their last connection closes.
+func (t *TypingTracker) clearUser(userID uint) {
+	cleared := make(map[typingKey][]uint)
+
+	t.mu.Lock()
+	for key, entry := range t.entries {
+		if key.userID == userID {
+			cleared[key] = entry.recipients
+			delete(t.entries, key)
+		}
+	}
+	delete(t.lastUpdate, userID)
+	t.mu.Unlock()
+
+	for key, recipients := range cleared {
+		t.broadcast(key, recipients, false)
+	}
+}
+
+func (t *TypingTracker) broadcast(key typingKey, recipients []uint, isTyping bool) {
+	for _, recipientID := range recipients {
+		if recipientID == key.userID {
+			continue
+		}
+		t.hub.BroadcastToUser(recipientID, map[string]interface{}{
+			"type":      "TYPING_STATUS",
+			"thread_id": key.threadID,
+			"user_id":   key.userID,
+			"is_typing": isTyping,
+		})
+	}
+}